@@ -9,6 +9,9 @@ import (
 const (
 	__ADDRESS_BOOK_FILE = "./addressbook.json"
 	__ID_RSA_FILE       = "./id_rsa"
+	__SSH_USERS_FILE    = "./sshusers.json"
+	__AUTH_KEYS_FILE    = "./authorized_keys"
+	__KNOWN_HOSTS_FILE  = "./known_hosts"
 	__SERIAL_SPEED      = 115200
 	__TELNET_PORT       = 20000
 	__SSHD_PORT         = 22000
@@ -18,11 +21,20 @@ var flags struct {
 	syslog      bool
 	logfile     string
 	serialPort  string
+	ptyLink     string
 	serialSpeed int
 	phoneBook   string
 	telnetPort  uint
 	sshdPort    uint
 	privateKey  string
+	sshUsers    string
+	sshKeys     string
+	sshOpen     bool
+	knownHosts  string
+	allowFrom   string
+	denyFrom    string
+	callRate    int
+	audio       string
 	skipTelnet  bool
 	skipSSH     bool
 }
@@ -40,7 +52,10 @@ func initFlags() {
 		"Default log `file` (default stderr)")
 
 	flag.StringVar(&flags.serialPort, "serial", "",
-		"Serial `device` (eg, /dev/ttyS0)")
+		"Serial `device` (eg, /dev/ttyS0, 'pty' or 'tcp:[host]:port'); comma separate for a modem bank")
+
+	flag.StringVar(&flags.ptyLink, "ptylink", "",
+		"Symlink `file` pointing at the pty when using -serial pty (line number appended in a bank)")
 
 	flag.IntVar(&flags.serialSpeed, "speed", __SERIAL_SPEED,
 		"Serial Port `speed` (bps) between DTE and DCE")
@@ -57,6 +72,30 @@ func initFlags() {
 	flag.StringVar(&flags.privateKey, "keyfile", __ID_RSA_FILE,
 		"SSH Private Key `file`")
 
+	flag.StringVar(&flags.sshUsers, "sshusers", __SSH_USERS_FILE,
+		"SSH users and bcrypt passwords `file`")
+
+	flag.StringVar(&flags.sshKeys, "authorizedkeys", __AUTH_KEYS_FILE,
+		"SSH authorized_keys `file` for inbound sessions")
+
+	flag.BoolVar(&flags.sshOpen, "sshopen", false,
+		"Let anyone log in over SSH if there are no SSH users or authorized_keys (default false)")
+
+	flag.StringVar(&flags.knownHosts, "knownhosts", __KNOWN_HOSTS_FILE,
+		"SSH known_hosts `file` for outbound calls")
+
+	flag.StringVar(&flags.allowFrom, "allow", "",
+		"Only take calls from these `addresses` (comma separated CIDR blocks)")
+
+	flag.StringVar(&flags.denyFrom, "deny", "",
+		"Never take calls from these `addresses` (comma separated CIDR blocks)")
+
+	flag.IntVar(&flags.callRate, "callrate", 0,
+		"Most `calls` a minute from one address (default 0, no limit)")
+
+	flag.StringVar(&flags.audio, "audio", "",
+		"Speaker `output`: alsa, alsa:device, |command or a WAV file (default none; .wav files get the line number in a bank)")
+
 	flag.BoolVar(&flags.skipTelnet, "notelnet", false,
 		"Don't start telnet server (default false)")
 
@@ -1,56 +1,24 @@
 package main
 
 //
-// Pretend to be a Hayes modem.
-//
-// References:
-// - Hayes command/error documentation:
-//    http://www.messagestick.net/modem/hayes_modem.html#Introduction
-// - Sounds: https://en.wikipedia.org/wiki/Precise_Tone_Plan
-// - RS232: https://en.wikipedia.org/wiki/RS-232
-// - Serial Programming: https://en.wikibooks.org/wiki/Serial_Programming
-// - Raspberry PI lib: github.com/stianeikeland/go-rpio
+// Pretend to be a Hayes modem.  The modem itself lives in hayes/modem,
+// this is just the command line wrapper around it.
 //
 
 import (
+	"fmt"
+	"hayes/modem"
+	"io"
+	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
-)
-
-// What mode is the modem in?
-const (
-	COMMANDMODE bool = false
-	DATAMODE    bool = true
 )
 
-// Basic modem state.  This is ephemeral.
-type Modem struct {
-	currentConfig int             // Which stored config are we using
-	mode          bool            // DATA or COMMAND mode
-	lastCmd       string          // Last command (for A/ command)
-	lastDialed    string          // Last number dialed (for ATDL)
-	connectSpeed  int             // What speed did we connect at (0 or 38k)
-	dcd           bool            // Data Carrier Detect -- active connection?
-	lineBusy      bool            // Is the "phone line" busy?
-	hook          bool            // Is the phone on or off hook?
-	conn          connection      // Current active connection
-}
-
-var m Modem
-var conf Config
-var registers *Registers
-var phonebook *Phonebook
-var profiles *storedProfiles
-var serial *serialPort
-var callChannel chan connection
-var last_ring_time time.Time
-
 // Catch ^C, reset the HW pins
 // Must be a goroutine
-func handleSignals() {
+func handleSignals(lines []*modem.Modem, pins modem.Pins, dtes []io.Closer) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGQUIT)
 
@@ -60,48 +28,96 @@ func handleSignals() {
 		logger.Print("Caught signal: ", s)
 		switch s {
 		case syscall.SIGINT:
-			clearPins()
+			pins.Clear()
+			for _, dte := range dtes {
+				dte.Close()
+			}
 			logger.Print("Exiting")
 			os.Exit(0)
 
 		case syscall.SIGQUIT:
-			logState()
+			for _, m := range lines {
+				m.LogState()
+			}
 		}
 	}
 }
 
-// Boot the modem
+// Boot the modem bank
 func main() {
+	var lines []*modem.Modem
+	var dtes []io.Closer
+	var hwPins modem.Pins
+
 	initFlags()
 
 	logger = setupLogging()
 	logger.Print("------------ Starting up")
 	logger.Printf("Cmdline: %s", strings.Join(os.Args, " "))
-	
-	// Setup the GPIO and serial port hardware
-	setupPins()
-	serial = setupSerialPort(flags.serialPort, flags.serialSpeed)
 
-	go handleSignals()	// Catch signals in a different thread
+	settings := modem.Settings{
+		PhoneBook:  flags.phoneBook,
+		TelnetPort: flags.telnetPort,
+		SSHPort:    flags.sshdPort,
+		PrivateKey: flags.privateKey,
+		SSHUsers:   flags.sshUsers,
+		SSHKeys:    flags.sshKeys,
+		SSHOpen:    flags.sshOpen,
+		KnownHosts: flags.knownHosts,
+		AllowFrom:  flags.allowFrom,
+		DenyFrom:   flags.denyFrom,
+		SkipTelnet: flags.skipTelnet,
+		SkipSSH:    flags.skipSSH,
+
+		CallsPerMinute: flags.callRate,
+		Audio:          flags.audio,
+	}
 
-	// Setup modem inital state
-	registers = NewRegisters()
-	factoryReset()
+	// One line per serial device
+	ports := strings.Split(flags.serialPort, ",")
+	for i, port := range ports {
+		l := logger
+		ptyLink := flags.ptyLink
+		s := settings
+		if len(ports) > 1 {
+			line := i + 1
+			l = log.New(logger.Writer(),
+				fmt.Sprintf("%sline %d: ", logger.Prefix(), line),
+				logger.Flags())
+			if ptyLink != "" {
+				ptyLink += fmt.Sprint(line)
+			}
+			if strings.HasSuffix(s.Audio, ".wav") {
+				s.Audio = fmt.Sprintf("%s%d.wav",
+					strings.TrimSuffix(s.Audio, ".wav"), line)
+			}
+			if line > 1 {
+				s.Profiles = fmt.Sprintf("hayes.config.%d.json", line)
+			}
+		}
 
-	// Setup the "hardware"
-	setupHW()
+		// There's only one set of GPIO pins, they belong to
+		// the first line.
+		var pins modem.Pins
+		if i == 0 {
+			hwPins = modem.NewHardwarePins(l)
+			pins = hwPins
+		} else {
+			pins = modem.NewSimulatedPins(l)
+		}
 
-	// Setup the comms channels and handle inbound/outbound comms
-	callChannel = make(chan connection)
-	go handleCalls()
+		dte, console := setupSerialPort(port, flags.serialSpeed, ptyLink)
+		m := modem.New(dte, pins, l, s)
+		if console != nil {
+			console.m = m
+		}
+		lines = append(lines, m)
+		dtes = append(dtes, dte)
+	}
 
-	time.Sleep(500 * time.Millisecond)
+	bank := modem.NewBank(lines, logger, settings)
 
-	// Tell user & DTE we're ready
-	raiseDSR()
-	raiseCTS()
-	logger.Print("Modem Ready")
-	prstatus(OK)
+	go handleSignals(lines, hwPins, dtes) // Catch signals in a different thread
 
-	handleSerial()	        // never returns
+	bank.Run() // never returns
 }
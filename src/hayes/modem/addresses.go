@@ -0,0 +1,142 @@
+package modem
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long to give DNS for each host in the address book
+const __RESOLVE_TIMEOUT = 2 * time.Second
+
+// The address book's entries by IP address, for working out who's
+// calling.  Resolving every host takes a while, so it's done once, and
+// again only when the file changes (AT&Z, or an edit).
+type addressIndex struct {
+	filename string
+	log      *log.Logger
+
+	lock      sync.Mutex
+	modTime   time.Time
+	size      int64
+	byIP      map[string]PhonebookEntry
+	blacklist map[string]PhonebookEntry
+}
+
+func newAddressIndex(filename string, log *log.Logger) *addressIndex {
+	return &addressIndex{filename: filename, log: log}
+}
+
+// Re-read and resolve the address book if it's changed.  Must hold
+// x.lock.
+func (x *addressIndex) refresh() {
+	fi, err := os.Stat(x.filename)
+	if err != nil {
+		x.byIP, x.blacklist = nil, nil
+		x.modTime, x.size = time.Time{}, 0
+		return
+	}
+	if x.byIP != nil && fi.ModTime().Equal(x.modTime) &&
+		fi.Size() == x.size {
+		return
+	}
+	x.modTime, x.size = fi.ModTime(), fi.Size()
+
+	pb := NewPhonebook(x.filename, log.New(ioutil.Discard, "", 0))
+	if pb.Load() != nil {
+		x.byIP = make(map[string]PhonebookEntry)
+		x.blacklist = x.byIP
+		return
+	}
+
+	// In order, so the first entry for an address is the one we
+	// find.  All at once, so it takes no longer than the slowest.
+	var keys []int
+	for k := range pb.entries {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	addrs := make([][]string, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, e PhonebookEntry) {
+			defer wg.Done()
+			addrs[i] = resolveHost(e.Host)
+		}(i, pb.entries[k])
+	}
+	wg.Wait()
+
+	x.byIP = make(map[string]PhonebookEntry)
+	x.blacklist = make(map[string]PhonebookEntry)
+	for i, k := range keys {
+		e := pb.entries[k]
+		for _, a := range addrs[i] {
+			if _, ok := x.byIP[a]; !ok {
+				x.byIP[a] = e
+			}
+			if _, ok := x.blacklist[a]; !ok && e.Blacklisted {
+				x.blacklist[a] = e
+			}
+		}
+	}
+	x.log.Printf("Address book %s: %d addresses, %d blacklisted",
+		x.filename, len(x.byIP), len(x.blacklist))
+}
+
+// The addresses of an entry's host, if DNS answers in time
+func resolveHost(host string) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		__RESOLVE_TIMEOUT)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
+
+// The entry for a host at ip, if there is one
+func (x *addressIndex) lookup(ip string) (PhonebookEntry, bool) {
+	if x == nil {
+		return PhonebookEntry{}, false
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.refresh()
+	e, ok := x.byIP[ip]
+	return e, ok
+}
+
+// The blacklisted entry for a host at ip, if there is one
+func (x *addressIndex) blacklisted(ip string) (PhonebookEntry, bool) {
+	if x == nil {
+		return PhonebookEntry{}, false
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.refresh()
+	e, ok := x.blacklist[ip]
+	return e, ok
+}
+
+// Resolve the address book ahead of the first call
+func (x *addressIndex) prime() {
+	if x == nil {
+		return
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.refresh()
+}
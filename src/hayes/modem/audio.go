@@ -0,0 +1,392 @@
+package modem
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The speaker.  Call progress sounds are rendered as 8kHz 16-bit mono
+// PCM, in real time, to one of:
+//
+//	alsa or alsa:device	aplay(1), on the default or a given device
+//	|command		command's stdin, as a WAV stream
+//	file			a WAV file
+//
+// Nothing is written while the speaker is quiet, so a file only holds
+// the calls.
+
+const (
+	__AUDIO_RATE  = 8000
+	__AUDIO_CHUNK = 20 * time.Millisecond
+)
+
+// Somewhere to send samples.  end() is called when the speaker goes
+// quiet.
+type audioSink interface {
+	io.Writer
+	end() error
+}
+
+func openAudio(spec string) (audioSink, error) {
+	switch {
+	case spec == "alsa":
+		return &pipeSink{args: aplayArgs("")}, nil
+	case strings.HasPrefix(spec, "alsa:"):
+		return &pipeSink{args: aplayArgs(spec[len("alsa:"):])}, nil
+	case strings.HasPrefix(spec, "|"):
+		return &pipeSink{args: []string{"sh", "-c", spec[1:]},
+			wav: true}, nil
+	}
+	return newWavFile(spec)
+}
+
+func aplayArgs(device string) []string {
+	args := []string{"aplay", "-q", "-t", "raw", "-f", "S16_LE",
+		"-r", fmt.Sprint(__AUDIO_RATE), "-c", "1"}
+	if device != "" {
+		args = append(args, "-D", device)
+	}
+	return args
+}
+
+// A WAV header.  size is the number of bytes of samples, or
+// 0xffffffff for a stream of unknown length.
+func wavHeader(size uint32) []byte {
+	h := make([]byte, 44)
+	riff := size + 36
+	if size == 0xffffffff {
+		riff = size
+	}
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], riff)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(h[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1)  // Mono
+	binary.LittleEndian.PutUint32(h[24:], __AUDIO_RATE)
+	binary.LittleEndian.PutUint32(h[28:], __AUDIO_RATE*2)
+	binary.LittleEndian.PutUint16(h[32:], 2)  // Bytes per sample
+	binary.LittleEndian.PutUint16(h[34:], 16) // Bits per sample
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], size)
+	return h
+}
+
+// A command that plays what's written to it.  Started for each sound,
+// so it isn't holding the sound card between calls.
+type pipeSink struct {
+	args []string
+	wav  bool // Wants a WAV header first
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+}
+
+func (p *pipeSink) Write(b []byte) (int, error) {
+	if p.cmd == nil {
+		cmd := exec.Command(p.args[0], p.args[1:]...)
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return 0, err
+		}
+		if err = cmd.Start(); err != nil {
+			return 0, err
+		}
+		p.cmd, p.in = cmd, in
+		if p.wav {
+			if _, err = in.Write(wavHeader(0xffffffff)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return p.in.Write(b)
+}
+
+func (p *pipeSink) end() error {
+	if p.cmd == nil {
+		return nil
+	}
+	p.in.Close()
+	err := p.cmd.Wait()
+	p.cmd, p.in = nil, nil
+	return err
+}
+
+// A WAV file holding every sound since we started.  The header is
+// brought up to date whenever the speaker goes quiet.
+type wavFile struct {
+	f    *os.File
+	size uint32
+}
+
+func newWavFile(filename string) (*wavFile, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Write(wavHeader(0)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &wavFile{f: f}, nil
+}
+
+func (w *wavFile) Write(b []byte) (int, error) {
+	i, err := w.f.Write(b)
+	w.size += uint32(i)
+	return i, err
+}
+
+func (w *wavFile) end() error {
+	_, err := w.f.WriteAt(wavHeader(w.size), 0)
+	return err
+}
+
+// Renders sounds to a sink, in the background, one lot at a time: the
+// next sound or quiet() stops whatever's playing.  play() waits for its
+// sounds to finish; loop() carries on until stopped.
+type speaker struct {
+	out    audioSink
+	volume float64
+	log    *log.Logger
+
+	lock    sync.Mutex
+	playing bool          // Has out been written to since end()?
+	stop    chan struct{} // Closed to stop the sound playing
+	stopped chan struct{} // Closed once it has
+}
+
+func newSpeaker(spec string, log *log.Logger) *speaker {
+	if spec == "" {
+		return nil
+	}
+	out, err := openAudio(spec)
+	if err != nil {
+		log.Printf("Can't open audio output %s: %s", spec, err)
+		return nil
+	}
+	log.Printf("Speaker output: %s", spec)
+	return &speaker{out: out, log: log}
+}
+
+// ATL0 to ATL3
+var speakerVolumes = []float64{0.15, 0.25, 0.5, 1.0}
+
+// Takes effect from the next sound
+func (s *speaker) setVolume(l int) {
+	if l < 0 || l >= len(speakerVolumes) {
+		l = len(speakerVolumes) - 1
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.volume = speakerVolumes[l]
+}
+
+// Write d (< 0 == forever) of snd to out, in real time, unless stop is
+// closed first.  Returns false if it was.
+func (s *speaker) render(snd sound, d time.Duration, volume float64,
+	stop chan struct{}) bool {
+
+	n := int(__AUDIO_CHUNK.Seconds() * __AUDIO_RATE)
+	buf := make([]byte, n*2)
+	start := time.Now()
+	total := int(d.Seconds() * __AUDIO_RATE)
+	for i := 0; d < 0 || i < total; i += n {
+		if d >= 0 && total-i < n {
+			n = total - i
+		}
+		for j := 0; j < n; j++ {
+			t := float64(i+j) / __AUDIO_RATE
+			v := math.Max(-1, math.Min(1, snd(t))) * volume * 0.9
+			binary.LittleEndian.PutUint16(buf[j*2:],
+				uint16(int16(v*32767)))
+		}
+		if _, err := s.out.Write(buf[:n*2]); err != nil {
+			s.log.Printf("Speaker: %s", err)
+			return false
+		}
+
+		// Keep to the wall clock
+		next := start.Add(time.Duration(i+n) * time.Second /
+			__AUDIO_RATE)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
+// Stop whatever's playing.  Must hold s.lock.
+func (s *speaker) stopLoop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.stopped
+		s.stop, s.stopped = nil, nil
+	}
+}
+
+// Start each sound playing for its duration, in the background, in
+// place of whatever was.  Returns a channel that's closed once they're
+// over.  Must hold s.lock.
+func (s *speaker) start(sounds ...timedSound) chan struct{} {
+	s.stopLoop()
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.playing = true
+	go func(volume float64, stop, stopped chan struct{}) {
+		defer close(stopped)
+		for _, ts := range sounds {
+			if !s.render(ts.snd, ts.d, volume, stop) {
+				return
+			}
+		}
+	}(s.volume, s.stop, s.stopped)
+	return s.stopped
+}
+
+// Play each sound for its duration, and return when they're done, or
+// stop them when ctx is
+func (s *speaker) play(ctx context.Context, sounds ...timedSound) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	done := s.start(sounds...)
+	s.lock.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.lock.Lock()
+		if s.stopped == done { // Nothing else has started since
+			s.stopLoop()
+		}
+		s.lock.Unlock()
+	}
+}
+
+// Play snd until told otherwise
+func (s *speaker) loop(snd sound) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.start(timedSound{snd, -1})
+}
+
+// Silence, until the next sound
+func (s *speaker) quiet() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopLoop()
+	if s.playing {
+		if err := s.out.end(); err != nil {
+			s.log.Printf("Speaker: %s", err)
+		}
+		s.playing = false
+	}
+}
+
+// Play sounds, if the speaker is on to hear them, unless ctx is done
+// first
+func (m *Modem) hear(ctx context.Context, sounds ...timedSound) {
+	if m.speakerOn(false) {
+		m.speaker.play(ctx, sounds...)
+	}
+}
+
+// Wait for d, hearing snd meanwhile if the speaker is on.  Gives up
+// early if ctx is done.
+func (m *Modem) pause(ctx context.Context, snd sound, d time.Duration) error {
+	if m.speakerOn(false) {
+		m.speaker.loop(snd)
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// number as DTMF (or pulses)
+func (m *Modem) digitSounds(ctx context.Context, pulse bool, number string) {
+	if pulse {
+		m.hear(ctx, pulses(number)...)
+	} else {
+		d := time.Duration(m.registers.Read(REG_MULTIFREQ_TONE_DURATION))
+		m.hear(ctx, dtmf(number, d*time.Millisecond)...)
+	}
+}
+
+// Ringback (and our calling tone, if we're sending it) while the call
+// goes through
+func (m *Modem) ringing(sendCallingTone bool) {
+	if !m.speakerOn(false) {
+		return
+	}
+	if sendCallingTone {
+		m.speaker.loop(mix(ringback, callingTone))
+	} else {
+		m.speaker.loop(ringback)
+	}
+}
+
+// Dial tone, host as DTMF, then ringback, for ATDH, ATDE and ATDR
+func (m *Modem) dialSounds(ctx context.Context, host string) {
+	m.hear(ctx, timedSound{dialTone, time.Second})
+	m.digitSounds(ctx, false, host)
+	m.ringing(false)
+}
+
+// How the call went: the handshake if it connected, the busy signal if
+// it didn't.  Cut short if ctx is done.
+func (m *Modem) progressSounds(ctx context.Context, result error) {
+	if !m.speakerOn(false) {
+		m.speaker.quiet()
+		return
+	}
+	switch result {
+	case CONNECT, OK:
+		m.speaker.play(ctx, handshake()...)
+		if m.speakerOn(true) {
+			m.speaker.loop(carrier)
+			return
+		}
+	case BUSY:
+		m.speaker.play(ctx, timedSound{busyTone, 2 * time.Second})
+	}
+	m.speaker.quiet()
+}
+
+// Is the speaker on, per ATM?  M0 never, M1 until the call connects, M2
+// all the time.
+func (m *Modem) speakerOn(connected bool) bool {
+	if m.speaker == nil {
+		return false
+	}
+	m.speaker.setVolume(m.conf.speakerVolume)
+	switch m.conf.speakerMode {
+	case 0:
+		return false
+	case 1:
+		return !connected
+	}
+	return true
+}
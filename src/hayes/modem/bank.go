@@ -0,0 +1,151 @@
+package modem
+
+import (
+	"log"
+)
+
+// A bank of modems sharing the telnet and SSH listeners.  Inbound
+// calls on the shared ports go to the first idle line (a hunt
+// group).  When there's more than one line, line n also listens on
+// the shared ports + n so callers can ring a specific line.
+type Bank struct {
+	lines     []*Modem
+	settings  Settings
+	log       *log.Logger
+	calls     chan Connection // Calls to the hunt group
+	sshAuth   *sshAuth        // Shared by all the SSH listeners
+	screen    *callScreen     // And which calls they all take
+	addresses *addressIndex   // Who's calling, by address
+}
+
+func NewBank(lines []*Modem, log *log.Logger, s Settings) *Bank {
+	var b Bank
+
+	b.lines = lines
+	b.settings = s
+	b.log = log
+	b.calls = make(chan Connection)
+
+	for i, m := range lines {
+		m.line = i + 1
+		m.bank = &b
+	}
+	return &b
+}
+
+// Busy only when no line is idle
+func (b *Bank) busy() bool {
+	for _, m := range b.lines {
+		if !m.checkBusy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Ring the first idle line for conn, so it's taken before the next call
+// arrives
+func (b *Bank) claimLine(conn Connection) *Modem {
+	for _, m := range b.lines {
+		if m.claim(conn) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Ports a line answers on directly, or 0 if it only answers through
+// the hunt group.
+func (b *Bank) linePorts(m *Modem) (uint, uint) {
+	if len(b.lines) == 1 {
+		return 0, 0
+	}
+	n := uint(m.line)
+	return b.settings.TelnetPort + n, b.settings.SSHPort + n
+}
+
+func (b *Bank) startAcceptingCalls(channel chan Connection, telnetPort uint,
+	sshPort uint, busy busyFunc, log *log.Logger) {
+	started_ok := make(chan error)
+
+	if b.settings.SkipTelnet {
+		log.Print("Telnet server not started by command line flag")
+	} else {
+		go acceptTelnet(channel, telnetPort, b.screen, busy, log,
+			started_ok)
+		if err := <-started_ok; err != nil {
+			log.Printf("Telnet server failed to start: %s", err)
+		} else {
+			log.Print("Telnet server started")
+		}
+	}
+
+	if b.settings.SkipSSH {
+		log.Print("SSH server not started by command line flag")
+	} else if b.sshAuth == nil {
+		log.Print("SSH server not started, no way to authenticate")
+	} else {
+		go acceptSSH(channel, sshPort, b.settings.PrivateKey,
+			b.sshAuth, b.screen, busy, log, started_ok)
+		if err := <-started_ok; err != nil {
+			log.Printf("SSH server failed to start: %s", err)
+		} else {
+			log.Print("SSH server started")
+		}
+	}
+}
+
+// Hand calls to the hunt group to the first idle line
+// Must be a goroutine
+func (b *Bank) hunt() {
+	for conn := range b.calls {
+		m := b.claimLine(conn)
+		if m == nil {
+			b.log.Printf("All lines busy, rejecting %s",
+				conn.RemoteAddr())
+			conn.Write([]byte("Busy...\n\r"))
+			conn.Close()
+			continue
+		}
+
+		b.log.Printf("Routing call from %s to line %d",
+			conn.RemoteAddr(), m.line)
+		if !offerCall(m.callChannel, conn, nil, b.log) {
+			m.unclaim(conn)
+		}
+	}
+}
+
+// Boot every modem in the bank.  Never returns.
+func (b *Bank) Run() {
+	b.addresses = newAddressIndex(b.settings.PhoneBook, b.log)
+	go b.addresses.prime()
+	s, err := newCallScreen(b.settings.AllowFrom, b.settings.DenyFrom,
+		b.settings.CallsPerMinute, b.addresses, b.log)
+	if err != nil {
+		b.log.Fatal(err)
+	}
+	b.screen = s
+
+	if !b.settings.SkipSSH {
+		a, err := newSSHAuth(b.settings.SSHUsers, b.settings.SSHKeys,
+			b.settings.SSHOpen, b.log)
+		if err != nil {
+			b.log.Print(err)
+		}
+		b.sshAuth = a
+	}
+	b.startAcceptingCalls(b.calls, b.settings.TelnetPort,
+		b.settings.SSHPort, b.busy, b.log)
+	go b.hunt()
+
+	for _, m := range b.lines {
+		if telnetPort, sshPort := b.linePorts(m); telnetPort != 0 {
+			b.startAcceptingCalls(m.callChannel, telnetPort,
+				sshPort, m.checkBusy, m.log)
+		}
+		go m.Run()
+	}
+
+	select {} // The lines do all the work
+}
@@ -0,0 +1,93 @@
+package modem
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// Caller ID, as the modem prints it between the first and second
+// rings.  There's no phone number on a network call, so the number is
+// the one in the address book for the caller's address, or failing that
+// the address itself.  The name is the caller's reverse DNS, or "O"
+// (out of area) if it has none.  If the caller told us their terminal
+// type and screen size (telnet TTYPE and NAWS, or an SSH pty), they
+// follow as TERM and SIZE.  All of it is worked out before the phone
+// starts ringing, so it's ready in time.
+
+// AT+VCID=n and AT#CID=n (both the same thing), n? and n=?
+func (m *Modem) callerIDCmd(cmd string) error {
+	c := strings.TrimPrefix(strings.TrimPrefix(cmd, "+VCID"), "#CID")
+	switch c {
+	case "?":
+		m.serial.Printf("%d\n", m.conf.callerID)
+	case "=?":
+		m.serial.Println("(0,1)")
+	case "=0":
+		m.conf.callerID = 0
+	case "=1":
+		m.conf.callerID = 1
+	default:
+		return ERROR
+	}
+	return OK
+}
+
+// A caller's number and name, and their terminal if they said
+type callerID struct {
+	number, name string
+	term         string
+	cols, rows   int
+}
+
+// Who's calling, in the modem's formatted caller ID style
+func (m *Modem) sendCallerID(id callerID) {
+	now := time.Now()
+	number, name := id.number, id.name
+	m.log.Printf("Caller ID: %s, %s", number, name)
+
+	m.serial.Println()
+	m.serial.Printf("DATE = %s\n", now.Format("0102"))
+	m.serial.Printf("TIME = %s\n", now.Format("1504"))
+	m.serial.Printf("NMBR = %s\n", number)
+	m.serial.Printf("NAME = %s\n", name)
+	if id.term != "" {
+		m.serial.Printf("TERM = %s\n", id.term)
+	}
+	if id.cols > 0 && id.rows > 0 {
+		m.serial.Printf("SIZE = %dx%d\n", id.cols, id.rows)
+	}
+	m.serial.Println()
+}
+
+func (m *Modem) identify(conn Connection) callerID {
+	ip := addrIP(conn.RemoteAddr())
+
+	var addresses *addressIndex
+	if m.bank != nil {
+		addresses = m.bank.addresses
+	}
+	number := ip
+	if e, ok := addresses.lookup(ip); ok {
+		number = e.Phone
+		if n, err := sanitizeNumber(e.Phone); err == nil {
+			number = n
+		}
+	}
+
+	name := "O"
+	ctx, cancel := context.WithTimeout(context.Background(),
+		__RESOLVE_TIMEOUT)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	id := callerID{number: number, name: name}
+	if t, ok := conn.(termConn); ok {
+		id.term, id.cols, id.rows = t.terminal()
+	}
+	return id
+}
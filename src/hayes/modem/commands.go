@@ -0,0 +1,470 @@
+package modem
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Show the user what our current network status is.
+func (m *Modem) networkStatus() error {
+	m.serial.Println("LISTENING ON:")
+	ifaces, _ := net.Interfaces()
+	for _, i := range ifaces {
+		addrs, _ := i.Addrs()
+		for _, a := range addrs {
+			ip, _, _ := net.ParseCIDR(a.String())
+			if !ip.IsMulticast() && !ip.IsLoopback() &&
+				!ip.IsUnspecified() && !ip.IsLinkLocalUnicast() {
+				m.serial.Printf("  Interface %s: %s\n", i.Name, ip)
+			}
+		}
+	}
+	m.serial.Println("ACTIVE PROTOCOLS:")
+	if m.bank != nil {
+		b := m.bank
+		telnetPort, sshPort := b.linePorts(m)
+		if !b.settings.SkipTelnet {
+			m.serial.Printf("  Telnet (%d)\n", b.settings.TelnetPort)
+			if telnetPort != 0 {
+				m.serial.Printf("  Telnet, line %d (%d)\n",
+					m.line, telnetPort)
+			}
+		}
+		if !b.settings.SkipSSH {
+			m.serial.Printf("  SSH (%d)\n", b.settings.SSHPort)
+			if sshPort != 0 {
+				m.serial.Printf("  SSH, line %d (%d)\n",
+					m.line, sshPort)
+			}
+		}
+	}
+
+	m.serial.Println("ACTIVE CONNECTION:")
+	if m.conn != nil {
+		m.serial.Printf("  %s\n", m.conn)
+	}
+		
+	return OK
+}
+
+// ATA.  A ringing call is answered by handleCalls(), which says
+// CONNECT once it has.
+func (m *Modem) answer() error {
+	if m.transition(RINGING, CONNECTED_DATA) {
+		return errPending
+	}
+	if !m.transition(IDLE, OFF_HOOK) {
+		m.log.Print("Can't answer, line off hook already")
+		return ERROR
+	}
+
+	// Nobody's calling, but listen for a carrier anyway;
+	// REG_CARRIER_DETECT_RESPONSE_TIME is in 1/10's of a second (100ms)
+	cd := m.registers.Read(REG_CARRIER_DETECT_RESPONSE_TIME)
+	time.Sleep(time.Duration(cd) * 100 * time.Millisecond)
+
+	m.log.Print("No carrier at ATA")
+	m.hangup()
+	return NO_CARRIER
+}
+
+
+// ATZn - 0 == config 0, 1 == config 1
+func (m *Modem) softReset(i int) error {
+	m.log.Printf("Switching config/registers")
+	if err := m.profiles.Switch(i, m); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AT&F - reset to factory defaults
+func (m *Modem) factoryReset() error {
+	err := OK
+	m.log.Print("Resetting modem")
+
+	// Reset state
+	m.hangup()
+	m.pins.LowerDSR()
+	m.pins.LowerCTS()
+	m.pins.LowerRI()
+	m.stopTimer()
+	m.currentConfig = 0
+	m.lastCmd = ""
+	m.lastDialed = ""
+	m.clearLineRate()
+	m.escSequence = [3]byte{'+', '+', '+'}
+
+	m.registers.Reset()
+	m.serial.setFlowControl(FLOW_NONE)
+	m.conf.Reset()
+	m.profiles, _ = newStoredProfiles(m.settings.Profiles, m.log)
+	m.profiles.Switch(m.profiles.PowerUpConfig, m)
+
+	m.phonebook = NewPhonebook(m.settings.PhoneBook, m.log)
+	err = m.phonebook.Load()
+	if err != nil {
+		m.log.Print(err)
+	}
+
+	m.resetTimer()
+
+	m.pins.RaiseCTS()
+	m.pins.RaiseDSR()
+	return err
+}
+
+// AT&V
+func (m *Modem) amperV() error {
+	m.serial.Println("ACTIVE PROFILE:")
+	m.serial.Println(m.conf.String())
+	m.serial.Println(m.registers.String())
+	m.serial.Println()
+	m.serial.Println(m.profiles)
+	m.serial.Println("TELEPHONE NUMBERS:")
+	m.serial.Println(m.phonebook)
+	return OK
+}
+
+// Given a parsed register command, execute it.
+func (m *Modem) registerCmd(cmd string) error {
+	var err error
+	var reg, val int
+
+	// NOTE: The order of these stanzas is critical.
+
+	// S? - query selected register
+	if cmd[:2] == "S?" {
+		m.serial.Printf("%d\n", m.registers.ReadCurrent())
+		return nil
+	}
+
+	// Sn=x - write x to n
+	_, err = fmt.Sscanf(cmd, "S%d=%d", &reg, &val)
+	if err == nil {
+		if reg > __NUM_REGS || reg < 0 {
+			return fmt.Errorf("Register index over/underflow: %d", reg)
+		}
+		if val > 255 || val < 0 {
+			return fmt.Errorf("Register value over/underflow: %d", val)
+		}
+		
+		// Validate input and update modem state
+		if !validRegister(reg, byte(val)) {
+			return ERROR
+		}
+		switch reg {
+		case REG_AUTO_ANSWER:
+			if val == 0 {
+				m.pins.LED(AA_LED, false)
+			} else {
+				m.pins.LED(AA_LED, true)
+			}
+		case REG_ESC_CODE_GUARD_TIME:
+			m.resetTimer()
+		case REG_ESC_CH:
+			m.escSequence[0] = byte(val)
+			m.escSequence[1] = byte(val)
+			m.escSequence[2] = byte(val)
+		case REG_REMOTE_COLS, REG_REMOTE_ROWS:
+			return ERROR
+		}
+
+		m.registers.Write(reg, byte(val))
+		if reg == REG_TERM_COLS || reg == REG_TERM_ROWS {
+			m.resizeTerminal()
+		}
+		return OK
+	}
+
+	// Sn? - query register n
+	_, err = fmt.Sscanf(cmd, "S%d?", &reg)
+	if err == nil {
+		if reg > __NUM_REGS || reg < 0 {
+			return fmt.Errorf("Register index over/underflow: %d", reg)
+		}
+		m.log.Printf("Reading register %d", reg)
+		conn := m.activeConn()
+		if conn != nil &&
+			(reg == REG_REMOTE_COLS || reg == REG_REMOTE_ROWS) {
+			m.remoteTerminal(conn) // It may have changed
+		}
+		m.serial.Printf("%d\n", m.registers.Read(reg))
+		return OK
+	}
+
+	// Sn - slect register
+	_, err = fmt.Sscanf(cmd, "S%d", &reg)
+	if err == nil {
+		if reg > __NUM_REGS || reg < 0 {
+			return fmt.Errorf("Register index over/underflow: %d", reg)
+		}
+		m.registers.SetCurrent(reg)
+		return OK
+	}
+
+	if err != nil {
+		m.log.Printf("registers(): err = %s", err)
+	}
+	return err
+}
+
+// AT&...
+func (m *Modem) processAmpersand(cmd string) error {
+	if cmd[0] != '&' {
+		return fmt.Errorf("Malformed AT& command: %s", cmd)
+	}
+	m.log.Print(redact(cmd))
+	cmd = cmd[1:]
+
+	switch cmd[0] {
+	case 'C':
+		m.conf.dcdPinned = cmd[1] == '0'
+		return nil
+
+	case 'D':
+		switch cmd[1] {
+		case '0': m.conf.dtr = 0
+		case '1': m.conf.dtr = 1
+		case '2': m.conf.dtr = 2
+		case '3': m.conf.dtr = 3
+		default: return fmt.Errorf("Malformed AT& command: %s", cmd)
+		}
+
+	case 'F':
+		switch cmd[1] {
+		case '0':
+			return m.factoryReset()
+		}
+
+	case 'K':
+		switch cmd[1] {
+		case '0': m.serial.setFlowControl(FLOW_NONE)
+		case '3': m.serial.setFlowControl(FLOW_RTSCTS)
+		case '4': m.serial.setFlowControl(FLOW_XONXOFF)
+		default: return fmt.Errorf("Unsupported flow control: %s", cmd)
+		}
+
+	case 'S':
+		m.conf.dsrPinned = cmd[1] == '0'
+		return nil
+		
+	case 'V':
+		switch cmd[1] {
+		case '0':
+			return m.amperV()
+		default:
+			return fmt.Errorf("Malformed AT& command: %s", cmd)
+		}
+
+	case 'W':
+		switch cmd[1] {
+		case '0':
+			return m.profiles.writeActive(0, m)
+		case '1':
+			return m.profiles.writeActive(1, m)
+		}
+
+	case 'Y':
+		switch cmd[1] {
+		case '0':
+			return m.profiles.setPowerUpConfig(0)
+		case '1':
+			return m.profiles.setPowerUpConfig(1)
+		}
+
+	case 'Z':
+		var s string
+		var i int
+		if _, err := fmt.Sscanf(cmd, "Z%d=%s", &i, &s); err != nil {
+			m.log.Printf("%s", err)
+			return fmt.Errorf("Malformed AT& command: %s", cmd)
+		}
+		if s[0] == 'D' || s[0] == 'd' { // Extension
+			return m.phonebook.Delete(i)
+		}
+		return m.phonebook.Add(i, s, m.supportedProtocol)
+
+	// Faked out AT& commands
+	case 'A','B','G','J','L','M','O','Q','R','T','U','X':
+		return nil
+
+	default:
+		return nil
+	}
+
+	return nil
+}
+
+// process each command
+func (m *Modem) processSingleCommand(cmd string) error {
+	var status error
+
+	switch cmd[0] {
+	case 'A':
+		status = m.answer()
+
+	case 'Z':
+		var c int
+		switch cmd[1] {
+		case '0':
+			c = 0
+		case '1':
+			c = 1
+		}
+		status = m.softReset(c)
+
+	case 'E':
+		m.conf.echoInCmdMode = cmd[1] == '0'
+
+	case 'H':
+		switch cmd[1] {
+		case '0':
+			// NO CARRIER is for calls we didn't end
+			if err := m.hangup(); err != NO_CARRIER {
+				status = err
+			}
+		case '1':
+			status = m.pickup()
+		}
+
+	case 'I':
+		switch cmd[1] {
+		case '0':
+			m.serial.Println("14400")
+		case '1':
+			m.serial.Println("058") // From my Hayes Ultra 96
+		case '2':
+			m.prstatus(OK)
+			m.serial.Println()
+		case '3':
+			m.serial.Println("04-0045012 240 PASS")
+			m.serial.Println()
+			m.serial.Println("04-00471-3143 080 PASS")
+			m.serial.Println()
+			m.serial.Println("04-00472-3143 190 PASS")
+			m.serial.Println()
+		case '4':
+			m.serial.Println("a097841F284C6403F00000090")
+			m.serial.Println()
+			m.serial.Println("bF60437000")
+			m.serial.Println()
+			m.serial.Println("r1031111111010000")
+			m.serial.Println()
+			m.serial.Println("r3000111010000000")
+			m.serial.Println()
+		case '5':
+			m.serial.Println("004")
+			m.serial.Println("a 001 001 003 PASS")
+		}
+		status = OK
+
+	case 'Q':
+		m.conf.quiet = cmd[1] == '0'
+
+	case 'V':
+		m.conf.verbose = cmd[1] == '0'
+
+	case 'L':
+		switch cmd[1] {
+		case '0':
+			m.conf.speakerVolume = 0
+		case '1':
+			m.conf.speakerVolume = 1
+		case '2':
+			m.conf.speakerVolume = 2
+		case '3':
+			m.conf.speakerVolume = 3
+		}
+
+	case 'M':
+		switch cmd[1] {
+		case '0':
+			m.conf.speakerMode = 0
+		case '1':
+			m.conf.speakerMode = 1
+		case '2':
+			m.conf.speakerMode = 2
+		}
+
+	case 'O':
+		if !m.transition(CONNECTED_CMD, CONNECTED_DATA) {
+			status = ERROR
+		}
+
+	case 'W':
+		switch cmd[1] {
+		case '0':
+			m.conf.connectMsgSpeed = false
+		case '1', '2':
+			m.conf.connectMsgSpeed = true
+		default:
+			status = ERROR
+		}
+
+	case 'X': // Change result codes displayed
+		switch cmd[1] {
+		case '0':
+			m.conf.extendedResultCodes = false
+			m.conf.busyDetect = false
+			m.conf.blindDial = true
+		case '1', '2':
+			m.conf.extendedResultCodes = true
+			m.conf.busyDetect = false
+			m.conf.blindDial = cmd[1] == '1'
+		case '3', '4', '5', '6', '7':
+			m.conf.extendedResultCodes = true
+			m.conf.busyDetect = true
+			m.conf.blindDial = cmd[1] == '3'
+		}
+
+	case 'D':
+		status = m.dial(cmd)
+
+	case 'S':
+		status = m.registerCmd(cmd)
+
+	case '&':
+		status = m.processAmpersand(cmd)
+
+	case '*':
+		status = m.debug(cmd)
+
+	case '!':
+		status = m.networkStatus()
+
+	case '%':
+		switch cmd[1] {
+		case 'K':
+			status = m.hostKeys(cmd)
+		case 'T':
+			status = m.terminalType(cmd)
+		}
+
+	case '+', '#':
+		status = m.callerIDCmd(cmd)
+
+	case 'B', 'C', 'F', 'N', 'P', 'T', 'Y': // faked out commands
+		status = OK
+
+	default:
+		status = OK
+	}
+
+	return status
+}
+
+func (m *Modem) processCommands(commands []string) error {
+	var cmd string
+	var status error
+
+	for _, cmd = range commands {
+		m.log.Printf("Processing: %s", redact(cmd))
+		status = m.processSingleCommand(cmd)
+		if status != OK {
+			return status
+		}
+	}
+	return OK
+}
@@ -1,4 +1,4 @@
-package main
+package modem
 
 import (
 	"fmt"
@@ -13,10 +13,14 @@ type Config struct {
 	quiet               bool
 	connectMsgSpeed     bool
 	busyDetect          bool
+	blindDial           bool // No dial tone detection (X0, X1, X3)
 	extendedResultCodes bool
 	dcdPinned           bool
 	dsrPinned           bool
 	dtr                 int
+	flowControl         int    // &K: FLOW_NONE, FLOW_RTSCTS or FLOW_XONXOFF
+	termType            string // %T: terminal type for hosts, "" == default
+	callerID            int    // +VCID/#CID: 0 == off, 1 == formatted
 }
 
 func (c *Config) Reset() {
@@ -25,12 +29,16 @@ func (c *Config) Reset() {
 	c.verbose = true       // Text return codes
 	c.speakerVolume = 2    // moderate volume
 	c.speakerMode = 1      // on until other modem heard
-	c.busyDetect = true    // BUSY or OK result code?
+	c.busyDetect = true    // BUSY or NO CARRIER result code?
+	c.blindDial = false
 	c.extendedResultCodes = true
 	c.dcdPinned = true	// if true, DCD if fixed 'on'
 	c.connectMsgSpeed = true
 	c.dsrPinned = true	// if true, DSR is fixed 'on'
 	c.dtr = 0
+	c.flowControl = FLOW_NONE
+	c.termType = ""
+	c.callerID = 0
 }
 
 func (c *Config) String() string {
@@ -43,17 +51,8 @@ func (c *Config) String() string {
 	i := func(p int) string {
 		return fmt.Sprintf("%d ", p)
 	}
-	x := func(r, b bool) string {
-		if r == false && b == false {
-			return "0 "
-		}
-		if r == true && b == false {
-			return "1 "
-		}
-		if r == true && b == true {
-			return "7 "
-		}
-		return "0 "
+	x := func(r, b, blind bool) string {
+		return fmt.Sprintf("%d ", resultLevel(r, b, blind))
 	}
 
 	str := "B16 B1 B41 B60 "
@@ -65,20 +64,39 @@ func (c *Config) String() string {
 	str += "Q" + b(c.quiet)
 	str += "V" + b(c.verbose)
 	str += "W" + b(c.connectMsgSpeed)
-	str += "X" + x(c.extendedResultCodes, c.busyDetect)
+	str += "X" + x(c.extendedResultCodes, c.busyDetect, c.blindDial)
 	str += "Y0 "
 	str += "&A0 "
 	str += "&C" + b(c.dcdPinned)
 	str += "&D" + i(c.dtr)
 	str += "&G0 "
 	str += "&J0 "
-	str += "&K0 "
+	str += "&K" + i(c.flowControl)
 	str += "&Q5 "
 	str += "&R0 "
 	str += "&S" + b(c.dsrPinned)
 	str += "&T4 "
 	str += "&U0 "
 	str += "&X4 "
+	if c.termType != "" {
+		str += "%T=" + c.termType + " "
+	}
+	str += "+VCID=" + i(c.callerID)
 
 	return lineWrap(str, 80)
 }
+
+// The ATX level for the result codes and dial tone detection in use
+func resultLevel(extended, busy, blind bool) int {
+	switch {
+	case !extended:
+		return 0
+	case !busy && blind:
+		return 1
+	case !busy:
+		return 2
+	case blind:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,259 @@
+package modem
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"log"
+	"net"
+	"time"
+)
+
+type busyFunc func() bool
+
+// How long an inbound call waits to be taken before it's turned away
+const __OFFER_TIMEOUT = time.Second
+
+// Hand an inbound call over to be answered, unless whoever takes it
+// is busy (by now: the caller may have taken a while to set up).
+// Otherwise, or if it isn't taken in time, the caller gets a busy
+// signal.  busy may be nil.
+func offerCall(channel chan Connection, conn Connection, busy busyFunc,
+	log *log.Logger) bool {
+
+	if busy == nil || !busy() {
+		select {
+		case channel <- conn:
+			return true
+		case <-time.After(__OFFER_TIMEOUT):
+		}
+	}
+	log.Printf("Line busy, rejecting %s", conn.RemoteAddr())
+	conn.Write([]byte("Busy...\n\r"))
+	conn.Close()
+	return false
+}
+
+// Is the network connection inbound or outbound
+const (
+	INBOUND = iota
+	OUTBOUND
+)
+
+// Interface specification for a connection.  The byte counts behind
+// Stats() are bumped by the data pumps as others read them, so they're
+// kept with sync/atomic, first in their structs so they're 64 bit
+// aligned on 32 bit ARM.
+type Connection interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+	Close() error
+	RemoteAddr() net.Addr
+	Direction() int          // INBOUND or OUTBOUND
+	Stats() (uint64, uint64) // Bytes sent and received
+	String() string
+	SetDeadline(t time.Time) error
+}
+
+// Connections with an optional 8-bit clean mode (see REG_TELNET_BINARY)
+type binaryConn interface {
+	setBinary(mode byte)
+}
+
+// Connections that can tell the remote how big our screen is.  0 means
+// the protocol's default.
+type resizeConn interface {
+	resize(cols, rows int)
+}
+
+// Connections that know the remote's terminal type and screen size
+type termConn interface {
+	terminal() (string, int, int)
+}
+
+// Network -> DTE buffering.  Kept small, like a real modem's, so a slow
+// DTE pushes back on the remote rather than us queueing up minutes of
+// data.
+const (
+	__RX_BUFFER = 2048
+	__READ_SIZE = 1024
+)
+
+// Pass bytes from the remote dialer to the serial port (for now,
+// stdout) as long as we're offhook, we're in DATA MODE and we have
+// valid carrier (m.comm != nil)
+func (m *Modem) serviceConnection(conn Connection) {
+	var t time.Time
+	var timeout time.Duration
+
+	m.log.Printf("Servicing connection with remote %s", conn.RemoteAddr())
+
+	rx := newRingBuffer(__RX_BUFFER)
+	done := make(chan struct{})
+	go m.sendToDTE(rx, done)
+	defer func() {
+		if !m.carrier() { // We hung up, drop the rest
+			rx.Reset()
+		}
+		rx.Close()
+		<-done
+	}()
+
+	buf := make([]byte, __READ_SIZE)
+	for {
+		// If S30 is non-zero, set a timeout
+		b := m.registers.Read(REG_INACTIVITY_TIMER)
+		timeout = time.Duration(b) * 10 * time.Second
+		if timeout == time.Duration(0) {
+			t = time.Time{}
+		} else {
+			t = time.Now().Add(timeout)
+		}
+		if err := conn.SetDeadline(t); err != nil {
+			m.log.Printf("conn.SetDeadline(): %s", err)
+			return
+		}
+
+		i, err := conn.Read(buf)
+
+		if !m.carrier() {
+			m.log.Print("conn.Read(): No carrier at network read")
+			return
+		}
+
+		rx.Write(buf[:i]) // Blocks while the DTE is behind
+
+		if err != nil { // Remote hung up or ...
+			nerr, ok := err.(net.Error) // we timed out.
+			switch {
+			case ok && nerr.Timeout():
+				m.log.Printf("conn.Read(): triggered S30 timeout: %s",
+					timeout)
+			case ok && nerr.Temporary():
+				m.log.Printf("conn.Read(): temporary errory: %s",
+					err)
+				continue // Really? TODO
+			default:
+				m.log.Print("conn.Read(): ", err)
+			}
+			return
+		}
+	}
+}
+
+// Drain rx to the DTE at the line speed until it's closed and empty.
+// Must be a goroutine
+func (m *Modem) sendToDTE(rx *ringBuffer, done chan struct{}) {
+	defer close(done)
+
+	buf := make([]byte, __READ_SIZE)
+	for {
+		i, err := rx.Read(buf[:m.rxPace.chunk(len(buf))])
+		if err != nil {
+			return
+		}
+
+		// Data from the remote is dropped in command mode
+		if !m.dataMode() {
+			continue
+		}
+
+		// Wait for the DTE, unless we hang up in the meantime
+		for m.serial.stopped() && m.carrier() {
+			time.Sleep(__FLOW_POLL)
+		}
+		if !m.carrier() {
+			rx.Reset()
+			continue
+		}
+
+		// Send to the DTE, blink the RD LED
+		m.rxPace.wait(i)
+		m.pins.LED(RD_LED, true)
+		m.serial.Write(buf[:i])
+		m.pins.LED(RD_LED, false)
+	}
+}
+
+// Accept connection's from dial*() and accept*() functions.
+func (m *Modem) handleCalls() {
+	// Wait for a connection.  If it's an incoming call, answer
+	// it.  If it's an outgoing call or an answered incoming call,
+	// service it
+	var conn Connection
+	// CTS is left to flow control (see serialPort.hold()), so the DTE
+	// can send commands between calls
+	for {
+		m.pins.LowerDSR()
+
+		conn = <-m.callChannel
+
+		switch conn.Direction() {
+		case INBOUND:
+			// Unless the hunt group has claimed the line for it,
+			// the line may have got busy since the call was let in
+			if !m.takeCall(conn) {
+				m.log.Printf("Line busy, rejecting %s",
+					conn.RemoteAddr())
+				conn.Write([]byte("Busy...\n\r"))
+				conn.Close()
+				continue
+			}
+		case OUTBOUND:
+			// Hung up on between CONNECT and here?
+			if m.activeConn() != conn {
+				conn.Close()
+				continue
+			}
+		}
+
+		m.remoteTerminal(conn)
+		m.pins.RaiseDSR()
+
+		switch conn.Direction() {
+		case INBOUND:
+			m.log.Printf("Incomming call from %s", conn.RemoteAddr())
+			var id *callerID
+			if m.conf.callerID == 1 {
+				c := m.identify(conn)
+				id = &c
+			}
+			if !m.answerIncomming(conn, id) {
+				// Answered too late to be any use?
+				if m.activeConn() == conn &&
+					m.hangup() == NO_CARRIER {
+					m.prstatus(NO_CARRIER)
+				}
+				m.transition(RINGING, IDLE)
+				conn.Close()
+				m.dropCall(conn)
+				m.remoteTerminal(nil)
+				continue
+			}
+			m.setLineRate(m.lineSpeed())
+			m.prstatus(CONNECT)
+		case OUTBOUND:
+			m.log.Printf("Outgoing call to %s ", conn.RemoteAddr())
+		}
+
+		// We now have an established connection (either answered or dialed)
+		// so service it.
+		if b, ok := conn.(binaryConn); ok {
+			b.setBinary(m.registers.Read(REG_TELNET_BINARY))
+		}
+		m.serviceConnection(conn)
+
+		// Only one of us gets to hang up, so if it's us, the user
+		// didn't, and hasn't heard
+		if m.activeConn() == conn && m.hangup() == NO_CARRIER {
+			m.serial.Printf("\n")
+			m.prstatus(NO_CARRIER)
+		}
+		sent, recv := conn.Stats()
+		conn.Close()
+		m.dropCall(conn)
+		m.speaker.quiet()
+		m.remoteTerminal(nil)
+		m.log.Printf("Connection closed, sent %s recv %s",
+			bytefmt.ByteSize(sent), bytefmt.ByteSize(recv))
+
+	}
+}
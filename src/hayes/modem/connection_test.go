@@ -0,0 +1,64 @@
+package modem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// A call that goes nowhere, keeping what it's sent
+type fakeCall struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *fakeCall) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeCall) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 1234}
+}
+
+func (c *fakeCall) Direction() int                { return INBOUND }
+func (c *fakeCall) Stats() (uint64, uint64)       { return 0, 0 }
+func (c *fakeCall) SetDeadline(t time.Time) error { return nil }
+
+// Calls are only handed to someone who's ready for them, the rest get
+// a busy signal
+func TestOfferCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		busy      bool
+		listening bool
+		taken     bool
+	}{
+		{"taken", false, true, true},
+		{"busy", true, true, false},
+		{"nobody listening", false, false, false},
+	}
+	for _, tt := range tests {
+		channel := make(chan Connection)
+		if tt.listening {
+			go func() { <-channel }()
+		}
+		conn := &fakeCall{}
+		busy := func() bool { return tt.busy }
+
+		start := time.Now()
+		taken := offerCall(channel, conn, busy,
+			log.New(ioutil.Discard, "", 0))
+		if took := time.Since(start); took > 2*__OFFER_TIMEOUT {
+			t.Errorf("%s: took %s", tt.name, took)
+		}
+		if taken != tt.taken || conn.closed == tt.taken ||
+			(conn.String() == "") != tt.taken {
+			t.Errorf("%s: taken %t, closed %t, sent %q", tt.name,
+				taken, conn.closed, conn.String())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package modem
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SSH channels have no deadlines of their own, so for S30 and friends
+// an SSH connection reads through one of these.  A goroutine does the
+// actual reading, and Read() gives up waiting for it when the deadline
+// passes, just as net.Conn's does.  Nothing read is lost: it's there
+// for the next Read().
+
+// The error a Read() past its deadline gets.  It's a net.Error.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// One Read() from underneath
+type readResult struct {
+	data []byte
+	err  error
+}
+
+type deadlineReader struct {
+	rx      chan readResult
+	pending []byte
+	err     error // Sticky, once the reader underneath fails
+
+	lock     sync.Mutex
+	deadline time.Time
+	changed  chan struct{} // Closed when the deadline moves
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newDeadlineReader(r io.Reader) *deadlineReader {
+	d := &deadlineReader{rx: make(chan readResult),
+		changed: make(chan struct{}), done: make(chan struct{})}
+	go d.receive(r)
+	return d
+}
+
+// Must be a goroutine
+func (d *deadlineReader) receive(r io.Reader) {
+	for {
+		buf := make([]byte, __READ_SIZE)
+		i, err := r.Read(buf)
+		select {
+		case d.rx <- readResult{data: buf[:i], err: err}:
+		case <-d.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		d.lock.Lock()
+		deadline, changed := d.deadline, d.changed
+		d.lock.Unlock()
+
+		var t *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, timeoutError{}
+			}
+			t = time.NewTimer(wait)
+			timeout = t.C
+		}
+
+		select {
+		case r := <-d.rx:
+			d.pending, d.err = r.data, r.err
+		case <-timeout:
+			return 0, timeoutError{}
+		case <-changed: // Start again with the new deadline
+		}
+		if t != nil {
+			t.Stop()
+		}
+	}
+
+	i := copy(p, d.pending)
+	d.pending = d.pending[i:]
+	return i, nil
+}
+
+// The zero time means no deadline.  Takes effect on a Read() that's
+// already waiting, too.
+func (d *deadlineReader) SetDeadline(t time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.deadline = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// Has the deadline passed?  For writes, which can't be interrupted.
+func (d *deadlineReader) expired() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return !d.deadline.IsZero() && !time.Now().Before(d.deadline)
+}
+
+// Stop reading.  Close whatever is underneath too, or the goroutine
+// can sit in Read() until the remote sends something.
+func (d *deadlineReader) Close() {
+	d.doneOnce.Do(func() { close(d.done) })
+}
@@ -0,0 +1,112 @@
+package modem
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"fmt"
+	"runtime"
+)
+
+func (m *Modem) logf(format string, a ...interface{}) {
+	m.log.Printf(format, a...)
+}
+func (m *Modem) pf(format string, a ...interface{}) {
+	m.serial.Printf(format, a...)
+}
+
+type out func(string, ...interface{})
+
+// Debug function
+func (m *Modem) outputState(debugf out) {
+
+	debugf("Modem state:\n")
+	debugf(" line         : %d\n", m.line)
+	debugf(" currentconfig: %d\n", m.currentConfig)
+	debugf(" state        : %s\n", m.getState())
+	debugf(" lastCmd      : %s\n", redact(m.lastCmd))
+	debugf(" lastDialed   : %s\n", redact(m.lastDialed))
+	debugf(" connectSpeed : %d\n", m.getConnectSpeed())
+
+	debugf("Config:\n")
+	debugf(" echoInCmdMode : %t\n", m.conf.echoInCmdMode)
+	debugf(" speakerMode   : %d\n", m.conf.speakerMode)
+	debugf(" speakerVolume : %d\n", m.conf.speakerVolume)
+	debugf(" verbose       : %t\n", m.conf.verbose)
+	debugf(" quiet         : %t\n", m.conf.quiet)
+	debugf(" connctMsgSpeed: %t\n", m.conf.connectMsgSpeed)
+	debugf(" busyDetect    : %t\n", m.conf.busyDetect)
+	debugf(" blindDial     : %t\n", m.conf.blindDial)
+	debugf(" extResultCodes: %t\n", m.conf.extendedResultCodes)
+	debugf(" dcdPinned     : %t\n", m.conf.dcdPinned)
+	debugf(" dsrPinned     : %t\n", m.conf.dsrPinned)
+	debugf(" dtr           : %d\n", m.conf.dtr)
+
+	debugf("Phonebook:\n")
+	debugf("%s\n", m.phonebook.String())
+
+	debugf("Registers:\n")
+	debugf("Curent register: %d\n", m.registers.ShowCurrent())
+	debugf("%s\n", m.registers.String())
+
+	if m.conn != nil {
+		sent, recv := m.conn.Stats()
+		debugf("Connection: %s, tx: %s rx: %s\n", m.conn.RemoteAddr(),
+			bytefmt.ByteSize(sent), bytefmt.ByteSize(recv))
+	} else {
+		debugf("Connection: <Not connected>\n")
+	}
+	
+	debugf("%s\n", m.pins.String())
+	debugf("GoRoutines: %d\n", runtime.NumGoroutine())
+}
+
+func (m *Modem) showState() {
+	m.outputState(m.pf)
+}
+
+// Dump the modem state to the log (eg, on SIGQUIT)
+func (m *Modem) LogState() {
+	m.outputState(m.logf)
+}
+
+// Given a parsed register command, execute it.
+func (m *Modem) debug(cmd string) error {
+	m.log.Printf("cmd = '%s'", redact(cmd))
+
+	switch {
+	case cmd == "*":
+		m.showState()
+		m.LogState()
+	case cmd == "*ledtest":
+		m.pins.LEDTest(5)
+	default:
+		return fmt.Errorf("Bad debug command: %s", cmd)
+	}
+
+	return nil
+}
+
+
+// AT*... debug command
+// Given a string that looks like a "*" debug command, parse & normalize it
+func (m *Modem) parseDebug(cmd string) (string, int, error) {
+
+	m.log.Printf("parseDebug(): %s", redact(cmd))
+
+	// Naked AT*
+	if len(cmd) == 1 && cmd[0] == '*' {
+		return "*", 1, nil
+	}
+
+	// Too short
+	if len(cmd) < 2 {
+		return "", 0, fmt.Errorf("Bad command: %s", cmd)
+	}
+
+	// Doesn't start with debug character
+	if cmd[0] != '*' {
+		return "", 0, fmt.Errorf("Bad command: %s", cmd)
+	}
+
+	// It's OK!
+	return cmd, len(cmd), nil
+}
@@ -0,0 +1,387 @@
+package modem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+)
+
+// Places an outbound call to the host in an address book entry.  It
+// should give up when ctx is done: S7 has run out, or the call has been
+// abandoned.
+type Dialer func(ctx context.Context, e PhonebookEntry,
+	log *log.Logger) (Connection, error)
+
+// The protocols a modem with settings s speaks unless told otherwise
+func DefaultDialers(s Settings) map[string]Dialer {
+	raw := func(ctx context.Context, e PhonebookEntry,
+		log *log.Logger) (Connection, error) {
+		return dialRaw(ctx, e.Host, log)
+	}
+	return map[string]Dialer{
+		"TELNET": func(ctx context.Context, e PhonebookEntry,
+			log *log.Logger) (Connection, error) {
+			return dialTelnet(ctx, e, log)
+		},
+		"SSH": func(ctx context.Context, e PhonebookEntry,
+			log *log.Logger) (Connection, error) {
+			return dialSSH(ctx, e, s.KnownHosts, log)
+		},
+		"RAW": raw,
+		"TCP": raw,
+	}
+}
+
+func (m *Modem) supportedProtocol(proto string) bool {
+	_, ok := m.dialers[strings.ToUpper(proto)]
+	return ok
+}
+
+// Place a call with whichever dialer handles the entry's protocol, over
+// a line like the one the entry describes.  The other end has until ctx
+// is done to answer.
+func (m *Modem) dialEntry(ctx context.Context, e PhonebookEntry) (Connection,
+	error) {
+
+	d, ok := m.dialers[strings.ToUpper(e.Protocol)]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported protocol '%s'", e.Protocol)
+	}
+	m.terminalDefaults(&e)
+
+	conn, err := d(ctx, e, m.log)
+	if err != nil {
+		return nil, err
+	}
+
+	m.setLineRate(e.Speed)
+
+	if e.Latency > 0 || e.Noise > 0 {
+		conn = newNoisyLine(conn, e, m.log)
+	}
+	return conn, nil
+}
+
+// Using the phonebook mapping, fake out dialing a standard phone number
+// (ATDT5551212)
+func (m *Modem) dialNumber(ctx context.Context, phone string) (Connection,
+	error) {
+
+	entry, err := m.phonebook.Lookup(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	m.log.Printf("Dialing address book entry: %+v", entry.Host)
+
+	return m.dialEntry(ctx, entry)
+}
+
+func (m *Modem) dialStoredNumber(ctx context.Context,
+	idxstr string) (Connection, error) {
+
+	index, err := strconv.Atoi(idxstr)
+	if err != nil {
+		m.log.Print(err)
+		return nil, err
+	}
+
+	phone, err := m.phonebook.LookupStoredNumber(index)
+	if err != nil {
+		m.log.Print("Error: ", err)
+		return nil, ERROR // We want ATDS to return ERROR.
+	}
+	m.log.Print("-- phone number ", phone)
+	return m.dialModified(ctx, false, phone)
+}
+
+// host|username|password, where the password can be (or be followed
+// by) key=file, agent, agent=socket, cmd=command or subsystem=name
+func splitATDE(cmd string) (PhonebookEntry, error) {
+	s := strings.Split(cmd, "|")
+	if len(s) < 3 {
+		return PhonebookEntry{}, fmt.Errorf("Malformated ATDE command")
+	}
+	e := PhonebookEntry{Host: s[0], Protocol: "SSH", Username: s[1]}
+	sshOptions(&e, s[2:])
+	return e, nil
+}
+
+// ATD command (ATD, ATDT, ATDP, ATDL and the extensions ATDH (host), ATDE (SSH)
+// and ATDR (raw TCP)
+// See http://www.messagestick.net/modem/Hayes_Ch1-1.html on ATD... result codes
+//
+// The call is placed in the background, so the DTE can abandon it: the
+// result code comes once it's gone through, or hasn't.
+func (m *Modem) dial(to string) error {
+	cmd := to[1]
+	if cmd == 'L' {
+		if m.lastDialed == "" {
+			return ERROR
+		}
+		return m.dial(m.lastDialed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	call := &outboundCall{cancel: cancel}
+	m.stateLock.Lock()
+	if _, ok := m.changeState(DIALING); !ok {
+		m.unlockState()
+		cancel()
+		m.log.Print("Can't dial, line busy")
+		return ERROR
+	}
+	m.dialing = call
+	m.unlockState()
+
+	// Now we know the dial command isn't Dial Last (ATDL), save
+	// this number as last dialed
+	m.lastDialed = to
+
+	go m.placeCall(ctx, call, to)
+	return errPending
+}
+
+// Dial to, then hand the call to handleCalls() if it went through.
+// Must be a goroutine.
+func (m *Modem) placeCall(ctx context.Context, call *outboundCall,
+	to string) {
+
+	conn, status := m.callOut(ctx, to)
+	m.progressSounds(ctx, status)
+
+	state := CONNECTED_DATA
+	if status == OK { // ATD...;
+		state = CONNECTED_CMD
+	}
+	if !m.endDialing(call, conn, state) { // Abandoned, and whoever did it said so
+		if conn != nil {
+			conn.Close()
+		}
+		return
+	}
+
+	if conn == nil {
+		m.hangup()
+		m.prstatus(status)
+		return
+	}
+
+	// CONNECT first, so nothing from the other end gets to the DTE
+	// ahead of it
+	m.prstatus(status)
+	m.callChannel <- conn
+}
+
+// Place the call for ATD... to, returning the connection if it went
+// through, and the result code either way.  S7 counts from here, so
+// pauses in the number count against it.
+func (m *Modem) callOut(ctx context.Context, to string) (Connection, error) {
+	var conn Connection
+	var err error
+	var clean_to string
+
+	ctx, cancel := context.WithTimeout(ctx,
+		m.seconds(REG_WAIT_FOR_CARRIER_AFTER_DIAL))
+	defer cancel()
+
+	cmd := to[1]
+
+	// Host names don't have dial modifiers, but strip them out anyway.
+	r := strings.NewReplacer(
+		",", "",
+		"@", "",
+		"W", "",
+		" ", "",
+		"!", "",
+		";", "")
+
+	// Is this ATD<number>?  If so, dial it
+	if unicode.IsDigit(rune(cmd)) {
+		conn, err = m.dialModified(ctx, false, to[1:])
+	} else { // ATD<modifier>
+
+		clean_to = r.Replace(to[2:])
+
+		switch cmd {
+		case 'H': // Hostname (ATDH hostname)
+			m.log.Print("Opening telnet connection to: ", clean_to)
+			m.dialSounds(ctx, clean_to)
+			conn, err = m.dialEntry(ctx, PhonebookEntry{Host: clean_to,
+				Protocol: "TELNET"})
+		case 'E': // Encrypted host (ATDE hostname)
+			// Passwords and commands need their spaces and
+			// punctuation, so no dial modifiers here
+			entry, e := splitATDE(strings.TrimSuffix(to[2:], ";"))
+			m.log.Print("Opening SSH connection to: ", entry.Host)
+			if e != nil {
+				m.log.Print(e)
+				conn = nil
+				err = e
+			} else {
+				m.dialSounds(ctx, entry.Host)
+				conn, err = m.dialEntry(ctx, entry)
+			}
+		case 'R': // Raw TCP socket (ATDR host:port)
+			m.log.Print("Opening TCP connection to: ", clean_to)
+			m.dialSounds(ctx, clean_to)
+			conn, err = m.dialEntry(ctx, PhonebookEntry{Host: clean_to,
+				Protocol: "RAW"})
+		case 'T', 'P': // Fake number from address book (ATDT 5551212)
+			m.log.Print("Dialing fake number: ", to[2:])
+			conn, err = m.dialModified(ctx, cmd == 'P', to[2:])
+		case 'S': // Stored number (ATDS3 or ATDS=3)
+			conn, err = m.dialStoredNumber(ctx,
+				strings.TrimPrefix(clean_to, "="))
+		default:
+			m.log.Printf("Dial mode '%c' not supported\n", cmd)
+			err = fmt.Errorf("Dial mode '%c' not supported", cmd)
+		}
+	}
+
+	// if we're connected, setup the connected state in the modem,
+	// otherwise return a BUSY, NO ANSWER or the like result code.
+	if err != nil {
+		status := dialStatus(err)
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			m.log.Print("No answer within S7 seconds")
+			status = NO_ANSWER
+		case context.Canceled: // Abandoned
+			status = NO_CARRIER
+		}
+		return nil, status
+	}
+
+	// ATD...; stays in command mode, so says OK rather than CONNECT
+	err = CONNECT
+	if strings.Contains(to, ";") {
+		err = OK
+	}
+
+	return conn, err
+}
+
+// The result code for a failed call.  A number that isn't there (in
+// the address book or DNS) or a call that's answered but can't be set
+// up is NO CARRIER; a refused connection is BUSY; one that's never
+// answered is NO ANSWER; and if there's no network to speak of, there's
+// NO DIALTONE.  result() turns some of these into NO CARRIER, depending
+// on ATX.
+func dialStatus(err error) error {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	if _, ok := err.(*MError); ok { // Already a result code
+		return err
+	}
+	switch {
+	case errors.As(err, &dnsErr) && !dnsErr.IsNotFound:
+		return NO_DIALTONE
+	case errors.Is(err, syscall.ENETUNREACH):
+		return NO_DIALTONE
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return BUSY
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return NO_ANSWER
+	}
+	return NO_CARRIER
+}
+
+// A call that's being dialed
+type outboundCall struct {
+	cancel context.CancelFunc
+}
+
+// What dial() and answer() return: there's no result code yet
+var errPending = errors.New("Pending")
+
+// Give up on the call being dialed, if there is one.  Returns false if
+// there isn't.  The result code is up to the caller, and so's hanging
+// up.
+func (m *Modem) abandonCall() bool {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	if m.dialing == nil {
+		return false
+	}
+	m.log.Print("Abandoning call")
+	m.dialing.cancel()
+	m.dialing = nil
+	return true
+}
+
+// Dialing's over, and if conn isn't nil, the call is up, in state.
+// Returns false if it was abandoned first.
+func (m *Modem) endDialing(call *outboundCall, conn Connection,
+	state lineState) bool {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	call.cancel()
+	if m.dialing != call {
+		return false
+	}
+	m.dialing = nil
+	if conn != nil {
+		m.conn = conn
+		m.changeState(state)
+	}
+	return true
+}
+
+// What a phone number can end with: digits and dial modifiers
+const __DIAL_CHARS = "0123456789#*,;@!WwRr^"
+
+func parseDial(cmd string) (string, int, error) {
+	var s string
+	var c int
+
+	if len(cmd) <= 1 {
+		return "", 0, fmt.Errorf("Bad/unsupported dial command: %s", cmd)
+	}
+
+	c = 1 // Skip the 'D'
+
+	// Parse 'ATD555555'
+	if unicode.IsDigit(rune(cmd[c])) {
+		e := strings.LastIndexAny(cmd, __DIAL_CHARS)
+		if e == -1 {
+			return "", 0, fmt.Errorf("Bad phone number: %s", cmd)
+		}
+		s = fmt.Sprintf("D%s", cmd[1:e+1])
+		return s, len(s), nil
+	}
+
+	switch cmd[c] {
+	case 'T', 't', 'P', 'p': // Number dialing
+		e := strings.LastIndexAny(cmd, __DIAL_CHARS)
+		if e == -1 {
+			return "", 0, fmt.Errorf("Bad phone number: %s", cmd)
+		}
+		s = fmt.Sprintf("D%c%s", unicode.ToUpper(rune(cmd[c])),
+			cmd[2:e+1])
+		return s, len(s), nil
+	case 'H', 'h': // Host Dialing
+		s = fmt.Sprintf("DH%s", cmd[c+1:])
+		return s, len(s), nil
+	case 'E', 'e': // Encrypted host Dialing
+		s = fmt.Sprintf("DE%s", cmd[c+1:])
+		return s, len(s), nil
+	case 'R', 'r': // Raw TCP Dialing
+		s = fmt.Sprintf("DR%s", cmd[c+1:])
+		return s, len(s), nil
+	case 'L', 'l': // Dial last number
+		s = fmt.Sprintf("DL")
+		return s, len(s), nil
+	case 'S', 's': // Dial stored number
+		s = fmt.Sprintf("DS%s", cmd[c+1:])
+		return s, len(s), nil
+	}
+
+	return "", 0, fmt.Errorf("Bad/unsupported dial command: %s", cmd)
+}
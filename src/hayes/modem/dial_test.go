@@ -0,0 +1,85 @@
+package modem
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A modem that dials over d, with S7 set to s7
+func testDialer(d Dialer, s7 byte) *Modem {
+	m := &Modem{log: log.New(ioutil.Discard, "", 0),
+		registers: NewRegisters(),
+		dialers:   map[string]Dialer{"RAW": d}}
+	m.registers.Reset()
+	m.registers.Write(REG_WAIT_FOR_CARRIER_AFTER_DIAL, s7)
+	return m
+}
+
+// Each way a call can go, and the result code for it
+func TestCallOut(t *testing.T) {
+	answer := func(ctx context.Context, e PhonebookEntry,
+		log *log.Logger) (Connection, error) {
+		return &fakeCall{}, nil
+	}
+	fail := func(err error) Dialer {
+		return func(ctx context.Context, e PhonebookEntry,
+			log *log.Logger) (Connection, error) {
+			return nil, err
+		}
+	}
+	ring := func(ctx context.Context, e PhonebookEntry,
+		log *log.Logger) (Connection, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	tests := []struct {
+		name   string
+		to     string
+		dialer Dialer
+		cancel bool // Abandoned while ringing
+		want   error
+	}{
+		{"answered", "DRhost:23", answer, false, CONNECT},
+		{"answered, stay in command mode", "DRhost:23;", answer, false,
+			OK},
+		{"refused", "DRhost:23", fail(syscall.ECONNREFUSED), false, BUSY},
+		{"no such host", "DRhost:23",
+			fail(&net.DNSError{Err: "no such host", Name: "host",
+				IsNotFound: true}), false, NO_CARRIER},
+		{"no network", "DRhost:23", fail(syscall.ENETUNREACH), false,
+			NO_DIALTONE},
+		{"never answered", "DRhost:23", ring, false, NO_ANSWER},
+		{"abandoned", "DRhost:23", ring, true, NO_CARRIER},
+		{"unknown dial mode", "DXhost:23", answer, false, NO_CARRIER},
+	}
+	for _, tt := range tests {
+		m := testDialer(tt.dialer, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancel {
+			time.AfterFunc(100*time.Millisecond, cancel)
+		}
+		start := time.Now()
+		conn, err := m.callOut(ctx, tt.to)
+		took := time.Since(start)
+		cancel()
+
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if (conn != nil) != (tt.want == CONNECT || tt.want == OK) {
+			t.Errorf("%s: connection %v", tt.name, conn)
+		}
+		if took > 3*time.Second {
+			t.Errorf("%s: took %s", tt.name, took)
+		}
+		if tt.cancel && took > time.Second/2 {
+			t.Errorf("%s: took %s to give up", tt.name, took)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package modem
+
+// Most bytes to take from the DTE buffer at once
+const __DTE_BATCH = 256
+
+// Consume bytes from the serial port and process them as commands, or
+// send them to the remote in data mode
+func (m *Modem) handleSerial() {
+	var CR, BS, ESC byte
+	var s string
+	var lastThree [3]byte
+	var idx int
+	var countAtTick, countAtLastTick uint64
+	var waitForOneTick bool
+	var afterCR bool // Was the last byte the CR ending a command?
+
+	in := make([]byte, __DTE_BATCH)
+	out := make([]byte, 0, __DTE_BATCH)
+
+	// Start accepting and processing bytes from the DTE
+	countAtTick = 0
+	for {
+
+		select {
+		case <-m.timer.C:
+			if !m.dataMode() { // Skip if in COMMAND mode
+				continue
+			}
+
+			// Look for the command escape sequence
+			// (see http://www.messagestick.net/modem/Hayes_Ch1-4.html)
+			// Basically:
+			// 1s of silence, "+++", 1s of silence.
+			// So, count the incoming chars between ticks, saving
+			// the previous tick's count.  If you see
+			// countAtTick == 3 && CountAtLastTick == 0 && the last
+			// three characters are "+++", wait one more tick.  If
+			// countAtTick == 0, the guard sequence was detected.
+
+			if countAtTick == 3 && countAtLastTick == 0 &&
+				lastThree == m.escSequence {
+				waitForOneTick = true
+			} else if waitForOneTick && countAtTick == 0 {
+				waitForOneTick = false
+				if !m.transition(CONNECTED_DATA, CONNECTED_CMD) {
+					continue // Hung up meanwhile
+				}
+				m.log.Print("Escape sequence detected, ",
+					"entering command mode")
+				m.prstatus(OK)
+				s = ""
+				continue
+			} else {
+				waitForOneTick = false
+			}
+			countAtLastTick = countAtTick
+			countAtTick = 0
+			continue
+
+		case <-m.serial.in.ready:
+		}
+
+		// Take no more than the line can carry in one go, so the
+		// timer above keeps ticking at slow speeds.
+		n := m.serial.in.TryRead(in[:m.txPace.chunk(len(in))])
+		m.serial.drained()
+
+		// DTEs that end lines with CR LF send the LF after the CR
+		// has run the command.  It's not a key press.
+		keys := in[:n]
+		if afterCR && len(keys) > 0 && keys[0] == '\n' {
+			keys = keys[1:]
+			afterCR = false
+		}
+
+		// Any key abandons a call that's being dialed, and goes no
+		// further
+		if len(keys) > 0 && m.abandonCall() {
+			m.log.Print("Key pressed")
+			m.hangup()
+			m.prstatus(NO_CARRIER)
+			continue
+		}
+
+		out = out[:0]
+		data := m.dataMode()
+		for _, c := range keys {
+			countAtTick++
+			lf := c == '\n' && afterCR
+			afterCR = false
+
+			// Syntatic helpers.  Reload each time we loop
+			CR  = m.registers.Read(REG_CR_CH)
+			BS  = m.registers.Read(REG_BS_CH)
+			ESC = m.registers.Read(REG_ESC_CH)
+
+			if !data {
+				if m.conf.echoInCmdMode { // Echo back to the DTE
+					m.serial.echoByte(c)
+				}
+
+				// Accumulate chars in s until we read a CR, then process
+				// s as a command.
+
+				// 'A/' command, immediately exec.
+				switch {
+				case  (s == "A" || s == "a") && c == '/':
+					m.serial.Println()
+					if m.lastCmd == "" {
+						m.prstatus(ERROR)
+					} else {
+						m.prstatus(m.runCommand(m.lastCmd))
+					}
+					s = ""
+
+				case c == CR && s != "":
+					m.prstatus(m.runCommand(s))
+					s = ""
+					afterCR = true
+
+				case lf:
+					// The rest of a CR LF
+
+				case c == BS && len(s) > 0:
+					s = s[0 : len(s)-1]
+
+				case c == CR || c == BS && len(s) == 0:
+					// ignore naked CR's & BS if s is already empty
+
+				default:
+					s += string(c)
+				}
+			} else {
+				// Look for the command escape sequence
+				switch c {
+				case ESC:
+					lastThree[idx] = c
+					idx = (idx + 1) % 3
+				default: 
+					lastThree = [3]byte{' ', ' ', ' '}
+					idx = 0
+				}
+				out = append(out, c)
+			}
+		}
+
+		// Send to remote, blinking the SD LED
+		if conn := m.activeConn(); len(out) > 0 && conn != nil {
+			m.txPace.wait(len(out))
+			m.pins.LED(SD_LED, true)
+			conn.Write(out)
+			m.pins.LED(SD_LED, false)
+		}
+	}
+}
+
@@ -1,56 +1,53 @@
-package main
+package modem
 
 import (
 	"time"
 )
 
-var dtrchan chan bool // "Interrupts" for DTR/S25 interactions
-
-
 // Clear the ring counter after 8s
 // Must be a goroutine
-func clearRingCounter() {
+func (m *Modem) clearRingCounter() {
 	delay := 8 * time.Second
 	for range time.Tick(delay) {
-		if time.Since(last_ring_time) >= delay {
-			registers.Write(REG_RING_COUNT, 0)
+		if time.Since(m.lastRingTime) >= delay {
+			m.registers.Write(REG_RING_COUNT, 0)
 		}
 	}
 }
 
 // Watch a subset of pins and/or config, and act as apropriate. 
 // Must be a goroutine
-func handlePins() {
+func (m *Modem) handlePins() {
 
 	for range time.Tick(250 * time.Millisecond) {
 
 		// Check connect speed, set HS LED
 		switch {
-		case m.connectSpeed > 19200:
-			led_HS_on()
+		case m.getConnectSpeed() > 19200:
+			m.pins.LED(HS_LED, true)
 		default:
-			led_HS_off()
+			m.pins.LED(HS_LED, false)
 		}
 		
 		// Check carrier, set CD LED
-		if conf.dcdPinned { // DCD is pinned high
-			raiseCD()
+		if m.conf.dcdPinned { // DCD is pinned high
+			m.pins.RaiseCD()
 		} else {
-			switch m.dcd { // DCD is set by m.dcd
-			case true:  raiseCD()
-			case false: lowerCD()
+			switch m.carrier() { // DCD is up during a call
+			case true:  m.pins.RaiseCD()
+			case false: m.pins.LowerCD()
 			}
 		}
 		
 		// Check dsrPinnedd
-		if conf.dsrPinned { // DSR is pinned high
-			raiseDSR() 
+		if m.conf.dsrPinned { // DSR is pinned high
+			m.pins.RaiseDSR() 
 		} 
 	}
 }
 
 // Handles DTR behavior as specified by &D and S25
-func handleDTR() {
+func (m *Modem) handleDTR() {
 	var d byte
 	var wasUp, waitForUp bool
 	var startDown time.Time
@@ -63,22 +60,22 @@ func handleDTR() {
 	for now := range time.Tick(5 * time.Millisecond) {
 
 		// First, see if the DTR detection time has changed
-		dt := registers.Read(REG_DTR_DETECTION_TIME)
+		dt := m.registers.Read(REG_DTR_DETECTION_TIME)
 		if d != dt {
 			d = dt
 			// REG_DTR_DETECTION_TIME is in 1/100ths of a second (10ms)
 			S25time = time.Duration(float64(d) * 10 ) * time.Millisecond
-			logger.Printf("DTR detection window: %s", S25time)
+			m.log.Printf("DTR detection window: %s", S25time)
 		}
 
-		if readDTR() {
+		if m.pins.ReadDTR() {
 			if !wasUp {
-				logger.Printf("DTR up, down for %s total",
+				m.log.Printf("DTR up, down for %s total",
 					now.Sub(startDown))
 			}
 			wasUp = true
 			waitForUp = false
-			led_TR_on()
+			m.pins.LED(TR_LED, true)
 			continue
 		}
 
@@ -92,58 +89,55 @@ func handleDTR() {
 
 		switch wasUp {
 		case true:	// DTR was up last time we looped
-			logger.Print("DTR down")
+			m.log.Print("DTR down")
 			startDown = now
 			wasUp = false
 			
 		case false:	// DTR was down last time we looped
 			down := now.Sub(startDown)
 			if down >= S25time {
-				logger.Print("Triggering processDTR()")
+				m.log.Print("Triggering processDTR()")
 				waitForUp = true
-				processDTR()
+				m.processDTR()
 			}
 		}
 	}
 }
 
 // If DTR is down, do what conf.dtr says:
-func processDTR() {
-	switch conf.dtr {
+func (m *Modem) processDTR() {
+	switch m.conf.dtr {
 	case 0:	// Do nothing, make sure LED is correct
-		logger.Print("DTR Toggled, &D0")
-		led_TR_off()
+		m.log.Print("DTR Toggled, &D0")
+		m.pins.LED(TR_LED, false)
 		
 	case 1:
-		led_TR_on()
-		logger.Print("DTR toggeled, &D1")
-		if m.mode == DATAMODE {
-			m.mode = COMMANDMODE
-			prstatus(OK)
+		m.pins.LED(TR_LED, true)
+		m.log.Print("DTR toggeled, &D1")
+		if m.transition(CONNECTED_DATA, CONNECTED_CMD) {
+			m.prstatus(OK)
 		}
 		
 	case 2:
-		logger.Print("DTR toggled, &D2")
-		led_TR_off()
-		if offHook() {
-			status := hangup()
-			prstatus(status)
+		m.log.Print("DTR toggled, &D2")
+		m.pins.LED(TR_LED, false)
+		if m.offHook() {
+			status := m.hangup()
+			m.prstatus(status)
 		}
 		
 	case 3:	// Reset modem
-		logger.Print("DTR toggled, &D3") 
-		err := softReset(m.currentConfig)
+		m.log.Print("DTR toggled, &D3") 
+		err := m.softReset(m.currentConfig)
 		if err != nil {
-			logger.Print("softReset() error: %s", err)
+			m.log.Printf("softReset() error: %s", err)
 		}
-		prstatus(err)
+		m.prstatus(err)
 	}
 }
 
-func setupHW() {
-	dtrchan = make(chan bool)
-
-	go clearRingCounter()
-	go handlePins()
-	go handleDTR()	   
+func (m *Modem) setupHW() {
+	go m.clearRingCounter()
+	go m.handlePins()
+	go m.handleDTR()	   
 }
@@ -0,0 +1,273 @@
+// +build arm
+
+package modem
+
+import (
+	"github.com/stianeikeland/go-rpio"
+	"log"
+	"strings"
+	"time"
+)
+
+// This assumes the MAX3232 does NOT do the level conversion between the Pi's
+// 0 and 3V low/high and RS-232 +5V/-5V.  So a "low" pin here is a High RPi output
+// vice versa.
+//
+// So note that the LED pins are normal, and the control pins (RTS, CTS, etc.)
+// are backwards (eg, pin.Low() means RS232 High and pin.High() means RS232 Low)
+
+type hwPins map[int]rpio.Pin
+
+type rpiPins struct {
+	leds hwPins
+	pins hwPins
+	log  *log.Logger
+}
+
+// LED and data pins (GPIO numbers)
+const (
+	// LEDs - controlled in handleLeds()
+	PI_HS_LED = 2  // Connected at High speed (conf.speed > 14400)
+	PI_AA_LED = 3  // Auto Answer configured (conf.r[0] > 0)
+	PI_TR_LED = 9  // Terminal Ready (turn on if read(DTR) is high)
+	PI_OH_LED = 27 // Is the modem off hook (m.offHook() == true)
+
+	// Receive and Send Data LEDs.  Manually controlled
+	PI_RD_LED = 10 // Receive Data
+	PI_SD_LED = 22 // Send Data
+
+	// Data Pins
+	// A MAX3232 translates these from 0V & 3V to RS232 -/+{3,5,12}V
+	PI_CTS_PIN = 12 // Clear To Send pin
+	PI_CS_LED  = 11 // Clear To Send LED
+
+	PI_RI_PIN = 23 // Ring Indicator pin
+	PI_RI_LED = 4  // Ring Indicator LED
+
+	PI_CD_PIN = 24 // Carrier Detect pin
+	PI_CD_LED = 17 // Carrier Detect LED
+
+	PI_DSR_PIN = 25 // Data Set Ready pin
+	PI_MR_LED  = 5  // Modem Ready LED
+
+	PI_RTS_PIN = 7  // Request to Send pin (Input)
+	PI_DTR_PIN = 16 // Data Terminal Ready (Input)
+)
+
+// Map the generic LEDs to GPIO pins
+var piLED = [_LED_LEN]int{
+	HS_LED: PI_HS_LED,
+	AA_LED: PI_AA_LED,
+	TR_LED: PI_TR_LED,
+	OH_LED: PI_OH_LED,
+	RD_LED: PI_RD_LED,
+	SD_LED: PI_SD_LED,
+	RI_LED: PI_RI_LED,
+	CD_LED: PI_CD_LED,
+	MR_LED: PI_MR_LED,
+	CS_LED: PI_CS_LED,
+}
+
+func NewHardwarePins(log *log.Logger) Pins {
+	return &rpiPins{log: log}
+}
+
+func (p *rpiPins) Setup() {
+
+	p.log.Print("Setting up RPi pins")
+	if err := rpio.Open(); err != nil {
+		p.log.Fatal("Fatal Error: ", err)
+	}
+
+	// LEDs
+	p.leds = make(hwPins)
+
+	for _, l := range piLED {
+		p.leds[l] = rpio.Pin(l)
+		p.leds[l].Output()
+	}
+
+	// Pins
+	p.pins = make(hwPins)
+
+	p.pins[PI_CTS_PIN] = rpio.Pin(PI_CTS_PIN)
+	p.pins[PI_CTS_PIN].Output()
+
+	p.pins[PI_RI_PIN] = rpio.Pin(PI_RI_PIN)
+	p.pins[PI_RI_PIN].Output()
+
+	p.pins[PI_CD_PIN] = rpio.Pin(PI_CD_PIN)
+	p.pins[PI_CD_PIN].Output()
+
+	p.pins[PI_DSR_PIN] = rpio.Pin(PI_DSR_PIN)
+	p.pins[PI_DSR_PIN].Output()
+
+	p.pins[PI_DTR_PIN] = rpio.Pin(PI_DTR_PIN)
+	p.pins[PI_DTR_PIN].Input()
+
+	p.pins[PI_RTS_PIN] = rpio.Pin(PI_RTS_PIN)
+	p.pins[PI_RTS_PIN].Input()
+
+}
+
+func (p *rpiPins) Clear() {
+	for _, l := range p.leds {
+		l.Low()
+	}
+
+	p.pins[PI_RI_PIN].High()
+	p.pins[PI_CD_PIN].High()
+	p.pins[PI_DSR_PIN].High()
+	p.pins[PI_CTS_PIN].High()
+	// No need to do RTS and DTR
+}
+
+func (p *rpiPins) String() string {
+	pp := func(n string, pin rpio.Pin, up rpio.State) string {
+		var s string
+		if pin.Read() == up {
+			s = strings.ToUpper(n)
+		} else {
+			s = strings.ToLower(n)
+		}
+		s += " "
+		return s
+	}
+
+	s := "PINs: ["
+	s += pp("CTS", p.pins[PI_CTS_PIN], rpio.Low)
+	s += pp("RI_", p.pins[PI_RI_PIN], rpio.Low)
+	s += pp("DCD", p.pins[PI_CD_PIN], rpio.Low)
+	s += pp("DSR", p.pins[PI_DSR_PIN], rpio.Low)
+	s += pp("RTS", p.pins[PI_RTS_PIN], rpio.Low)
+	s += pp("DTR", p.pins[PI_DTR_PIN], rpio.Low)
+	s += "]"
+
+	s += "\n"
+
+	s += "LEDs: "
+	s += pp("HS", p.leds[PI_HS_LED], rpio.High)
+	s += pp("AA", p.leds[PI_AA_LED], rpio.High)
+	s += pp("RI", p.leds[PI_RI_LED], rpio.High)
+	s += pp("CD", p.leds[PI_CD_LED], rpio.High)
+	s += pp("OH", p.leds[PI_OH_LED], rpio.High)
+	s += pp("MR", p.leds[PI_MR_LED], rpio.High)
+	s += pp("CS", p.leds[PI_CS_LED], rpio.High)
+	s += pp("TR", p.leds[PI_TR_LED], rpio.High)
+	s += pp("SD", p.leds[PI_SD_LED], rpio.High)
+	s += pp("RD", p.leds[PI_RD_LED], rpio.High)
+	s += "]"
+	return s
+}
+
+// Led functions
+func (p *rpiPins) LED(led int, on bool) {
+	if on {
+		p.leds[piLED[led]].High()
+	} else {
+		p.leds[piLED[led]].Low()
+	}
+}
+
+func (p *rpiPins) LEDTest(round int) {
+	var saved_leds map[int]rpio.State
+
+	saved_leds = make(map[int]rpio.State)
+
+	// Turn them all on, wait a bit, turn them all off.
+	for i := range p.leds {
+		saved_leds[i] = p.leds[i].Read() // Save current state
+		p.leds[i].High()
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+	for i := range p.leds {
+		p.leds[i].Low()
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	// Randomly (based on how range works) turn on and off round times
+	for j := 0; j < round; j++ {
+		for i := range p.leds {
+			p.leds[i].High()
+			time.Sleep(50 * time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		for i := range p.leds {
+			p.leds[i].Low()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// Restore LED state
+	for j := range saved_leds {
+		p.leds[j].Write(saved_leds[j])
+	}
+
+}
+
+// PINs
+
+// RI - assert RI and turn on RI light
+func (p *rpiPins) RaiseRI() {
+	p.leds[PI_RI_LED].High()
+	p.pins[PI_RI_PIN].Low()
+}
+func (p *rpiPins) LowerRI() {
+	p.leds[PI_RI_LED].Low()
+	p.pins[PI_RI_PIN].High()
+}
+func (p *rpiPins) ReadRI() bool {
+	return p.pins[PI_RI_PIN].Read() == rpio.Low
+}
+
+// CD - assert CD and turn on CD light
+func (p *rpiPins) RaiseCD() {
+	p.leds[PI_CD_LED].High()
+	p.pins[PI_CD_PIN].Low()
+}
+func (p *rpiPins) LowerCD() {
+	p.leds[PI_CD_LED].Low()
+	p.pins[PI_CD_PIN].High()
+}
+func (p *rpiPins) ReadCD() bool {
+	return p.pins[PI_CD_PIN].Read() == rpio.Low
+}
+
+// DSR - assert DSR and turn on MR light
+func (p *rpiPins) RaiseDSR() {
+	p.leds[PI_MR_LED].High()
+	p.pins[PI_DSR_PIN].Low()
+}
+
+func (p *rpiPins) LowerDSR() {
+	p.leds[PI_MR_LED].Low()
+	p.pins[PI_DSR_PIN].High()
+}
+func (p *rpiPins) ReadDSR() bool {
+	return p.pins[PI_DSR_PIN].Read() == rpio.Low
+}
+
+// CTS - assert CTS and turn on CS light
+func (p *rpiPins) RaiseCTS() {
+	p.leds[PI_CS_LED].High()
+	p.pins[PI_CTS_PIN].Low()
+}
+func (p *rpiPins) LowerCTS() {
+	p.leds[PI_CS_LED].Low()
+	p.pins[PI_CTS_PIN].High()
+}
+func (p *rpiPins) ReadCTS() bool {
+	return p.pins[PI_CTS_PIN].Read() == rpio.Low
+}
+
+// DTR (input)
+func (p *rpiPins) ReadDTR() bool {
+	return p.pins[PI_DTR_PIN].Read() == rpio.Low
+}
+
+// RTS (input)
+func (p *rpiPins) ReadRTS() bool {
+	return p.pins[PI_RTS_PIN].Read() == rpio.Low
+}
@@ -0,0 +1,12 @@
+// +build !arm
+
+package modem
+
+import (
+	"log"
+)
+
+// No GPIO pins here, simulate them.
+func NewHardwarePins(log *log.Logger) Pins {
+	return NewSimulatedPins(log)
+}
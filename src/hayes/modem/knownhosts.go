@@ -0,0 +1,192 @@
+package modem
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Outbound SSH host keys live in an OpenSSH style known_hosts file.  A
+// host's key is trusted the first time we call it, and has to match
+// every time after that.
+
+// Default file for the known host keys
+const __KNOWN_HOSTS_FILE = "known_hosts"
+
+// Every line in a bank shares the one file
+var knownHostsLock sync.Mutex
+
+// The host presented a different key to the one we have on file
+var errHostKeyChanged = errors.New("SSH host key has changed")
+
+// Check a host's key against filename, recording it if the host is
+// new.  Sets *changed when the key doesn't match, as ssh.Dial() doesn't
+// hand back our error.
+func trustOnFirstUse(filename string, changed *bool,
+	log *log.Logger) ssh.HostKeyCallback {
+
+	return func(host string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsLock.Lock()
+		defer knownHostsLock.Unlock()
+
+		// knownhosts.New() insists the file exists
+		f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND,
+			0600)
+		if err != nil {
+			log.Printf("Can't open known hosts %s: %s", filename, err)
+			return err
+		}
+		defer f.Close()
+
+		check, err := knownhosts.New(filename)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+
+		err = check(host, remote, key)
+		var keyErr *knownhosts.KeyError
+		switch {
+		case err == nil:
+			return nil
+
+		case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+			log.Printf("New host %s, trusting its %s key %s", host,
+				key.Type(), ssh.FingerprintSHA256(key))
+			_, err = fmt.Fprintln(f, knownhosts.Line([]string{host}, key))
+			return err
+
+		case errors.As(err, &keyErr):
+			want := keyErr.Want[0]
+			log.Printf("WARNING: host key for %s has changed!  "+
+				"Got %s key %s, expected %s (%s:%d)", host,
+				key.Type(), ssh.FingerprintSHA256(key),
+				ssh.FingerprintSHA256(want.Key), want.Filename,
+				want.Line)
+			*changed = true
+			return errHostKeyChanged
+		}
+
+		log.Printf("Host key for %s rejected: %s", host, err)
+		return err
+	}
+}
+
+// One line per host key: host(s), key type and fingerprint
+func listKnownHosts(filename string) ([]string, error) {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for len(bytes.TrimSpace(b)) > 0 {
+		marker, names, key, _, rest, err := ssh.ParseKnownHosts(b)
+		if err != nil {
+			return hosts, err
+		}
+		s := fmt.Sprintf("%s %s %s", strings.Join(names, ","),
+			key.Type(), ssh.FingerprintSHA256(key))
+		if marker != "" {
+			s = "@" + marker + " " + s
+		}
+		hosts = append(hosts, s)
+		b = rest
+	}
+	return hosts, nil
+}
+
+// Drop every key for host (host or host:port).  Returns how many went.
+func forgetKnownHost(filename, host string) (int, error) {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	host = knownhosts.Normalize(strings.ToLower(host))
+	var keep bytes.Buffer
+	forgotten := 0
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if knownHostsMatch(line, host) {
+			forgotten++
+			continue
+		}
+		fmt.Fprintln(&keep, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if forgotten > 0 {
+		err = ioutil.WriteFile(filename, keep.Bytes(), 0600)
+	}
+	return forgotten, err
+}
+
+// Is line a key for host?  Markers and comments never are.
+func knownHostsMatch(line, host string) bool {
+	marker, names, _, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	if err != nil || marker != "" {
+		return false
+	}
+	for _, name := range names {
+		if strings.ToLower(name) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// AT%K - list the known SSH hosts, AT%K=host - forget host's key
+func (m *Modem) hostKeys(cmd string) error {
+	filename := m.settings.KnownHosts
+
+	if cmd == "%K" || cmd == "%K?" {
+		hosts, err := listKnownHosts(filename)
+		if err != nil {
+			m.log.Printf("Can't read known hosts %s: %s", filename, err)
+			return ERROR
+		}
+		m.serial.Println("KNOWN HOSTS:")
+		for _, h := range hosts {
+			m.serial.Printf("  %s\n", h)
+		}
+		return OK
+	}
+
+	host := strings.TrimPrefix(cmd, "%K=")
+	n, err := forgetKnownHost(filename, host)
+	if err != nil {
+		m.log.Printf("Can't update known hosts %s: %s", filename, err)
+		return ERROR
+	}
+	if n == 0 {
+		m.log.Printf("No known host key for %s", host)
+		return ERROR
+	}
+	m.log.Printf("Forgot %d host key(s) for %s", n, host)
+	return OK
+}
@@ -0,0 +1,165 @@
+package modem
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Connection over a less than perfect phone line.  Data from the
+// network is held back by the line's latency, and bytes in either
+// direction can take a hit from line noise.
+type noisyLine struct {
+	Connection
+	latency time.Duration
+	noise   float64
+	log     *log.Logger
+
+	rx       chan lineChunk
+	in       *deadlineReader // Reads rx as it falls due
+	pending  []byte          // Due, and not read yet
+	rxErr    error           // What ended the call, once pending's read
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// What's come down the line, once it's due.  Only l.in reads it.
+type lineDelay struct {
+	*noisyLine
+}
+
+// Data read from the network, and when it's due at the DTE
+type lineChunk struct {
+	data []byte
+	due  time.Time
+	err  error
+}
+
+func newNoisyLine(c Connection, e PhonebookEntry, log *log.Logger) *noisyLine {
+	l := &noisyLine{Connection: c,
+		latency: time.Duration(e.Latency) * time.Millisecond,
+		noise:   e.Noise, log: log,
+		rx:   make(chan lineChunk, 256),
+		done: make(chan struct{})}
+	log.Printf("Line latency %s, noise %g", l.latency, l.noise)
+	go l.receive()
+	l.in = newDeadlineReader(lineDelay{l})
+	return l
+}
+
+// Pull data off the network, stamping it with when it should arrive.
+// Must be a goroutine
+func (l *noisyLine) receive() {
+	defer close(l.rx)
+	for {
+		buf := make([]byte, 1024)
+		i, err := l.Connection.Read(buf)
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			// Read deadlines are ours to keep, not the connection's
+			l.Connection.SetDeadline(time.Time{})
+			err = nil
+			if i == 0 {
+				continue
+			}
+		}
+		c := lineChunk{data: buf[:i], due: time.Now().Add(l.latency),
+			err: err}
+		select {
+		case l.rx <- c:
+		case <-l.done:
+			return
+		}
+		if err != nil { // The call's over
+			return
+		}
+	}
+}
+
+// Randomly flip a bit in some bytes of p
+func (l *noisyLine) hit(p []byte) {
+	for i := range p {
+		if rand.Float64() < l.noise {
+			p[i] ^= 1 << uint(rand.Intn(8))
+		}
+	}
+}
+
+// Wait for the next chunk to fall due.  The wait's in l.in's goroutine,
+// so it doesn't hold up a Read() with a deadline.
+func (d lineDelay) Read(p []byte) (int, error) {
+	l := d.noisyLine
+	if len(l.pending) == 0 {
+		if l.rxErr != nil {
+			return 0, l.rxErr
+		}
+		var c lineChunk
+		var ok bool
+		select {
+		case c, ok = <-l.rx:
+		case <-l.done:
+		}
+		if !ok {
+			return 0, io.EOF
+		}
+		t := time.NewTimer(time.Until(c.due))
+		select {
+		case <-t.C:
+		case <-l.done:
+			t.Stop()
+			return 0, io.EOF
+		}
+		l.hit(c.data)
+		l.pending, l.rxErr = c.data, c.err
+		if len(c.data) == 0 {
+			return 0, c.err
+		}
+	}
+
+	i := copy(p, l.pending)
+	l.pending = l.pending[i:]
+	return i, nil
+}
+
+func (l *noisyLine) Read(p []byte) (int, error) {
+	return l.in.Read(p)
+}
+
+// Deadlines are for reads from the line, which is slower than the
+// connection underneath
+func (l *noisyLine) SetDeadline(t time.Time) error {
+	l.in.SetDeadline(t)
+	return nil
+}
+
+func (l *noisyLine) Write(p []byte) (int, error) {
+	if l.noise > 0 {
+		b := make([]byte, len(p))
+		copy(b, p)
+		l.hit(b)
+		p = b
+	}
+	return l.Connection.Write(p)
+}
+
+func (l *noisyLine) Close() error {
+	l.doneOnce.Do(func() { close(l.done) })
+	l.in.Close()
+	return l.Connection.Close()
+}
+
+// Pass binary mode through to the connection underneath
+func (l *noisyLine) setBinary(mode byte) {
+	if b, ok := l.Connection.(binaryConn); ok {
+		b.setBinary(mode)
+	}
+}
+
+// And screen size changes
+func (l *noisyLine) resize(cols, rows int) {
+	if r, ok := l.Connection.(resizeConn); ok {
+		r.resize(cols, rows)
+	}
+}
@@ -0,0 +1,84 @@
+package modem
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+// A call whose Read()s return what comes down rx
+type lineCall struct {
+	fakeCall
+	rx chan readResult
+}
+
+func (c *lineCall) Read(p []byte) (int, error) {
+	r, ok := <-c.rx
+	if !ok {
+		return 0, net.ErrClosed
+	}
+	return copy(p, r.data), r.err
+}
+
+func testLine(latency int) (*noisyLine, *lineCall) {
+	c := &lineCall{rx: make(chan readResult, 10)}
+	l := newNoisyLine(c, PhonebookEntry{Latency: latency},
+		log.New(ioutil.Discard, "", 0))
+	return l, c
+}
+
+// A read deadline is kept even while data's held back by the latency,
+// and nothing's lost when it passes
+func TestLineDeadline(t *testing.T) {
+	l, c := testLine(500)
+	defer l.Close()
+	c.rx <- readResult{data: []byte("hello")}
+
+	start := time.Now()
+	l.SetDeadline(start.Add(100 * time.Millisecond))
+	buf := make([]byte, 10)
+	n, err := l.Read(buf)
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() || n != 0 {
+		t.Fatalf("read %d, %v before the deadline", n, err)
+	}
+	if took := time.Since(start); took > 300*time.Millisecond {
+		t.Errorf("deadline passed after %s", took)
+	}
+
+	l.SetDeadline(time.Time{})
+	n, err = l.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("read %q, %v", buf[:n], err)
+	}
+	if took := time.Since(start); took < 500*time.Millisecond {
+		t.Errorf("arrived after %s", took)
+	}
+}
+
+// A timeout underneath doesn't end the call
+func TestLineTimeoutUnderneath(t *testing.T) {
+	l, c := testLine(0)
+	defer l.Close()
+	c.rx <- readResult{err: timeoutError{}}
+	c.rx <- readResult{data: []byte("still"), err: timeoutError{}}
+	c.rx <- readResult{data: []byte(" here")}
+	close(c.rx)
+
+	var got []byte
+	buf := make([]byte, 10)
+	for {
+		n, err := l.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			if err != net.ErrClosed {
+				t.Errorf("ended with %v", err)
+			}
+			break
+		}
+	}
+	if string(got) != "still here" {
+		t.Errorf("read %q", got)
+	}
+}
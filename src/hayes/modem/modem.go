@@ -0,0 +1,144 @@
+// Package modem pretends to be a Hayes modem.
+//
+// References:
+// - Hayes command/error documentation:
+//    http://www.messagestick.net/modem/hayes_modem.html#Introduction
+// - Sounds: https://en.wikipedia.org/wiki/Precise_Tone_Plan
+// - RS232: https://en.wikipedia.org/wiki/RS-232
+// - Serial Programming: https://en.wikibooks.org/wiki/Serial_Programming
+// - Raspberry PI lib: github.com/stianeikeland/go-rpio
+//
+package modem
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+
+// Default file for the stored profiles (AT&W, AT&Y)
+const __PROFILES_FILE = "hayes.config.json"
+
+// Things a Modem or Bank needs that aren't the DTE, the pins or the
+// logger.  The listeners are shared by every line in a Bank.
+type Settings struct {
+	PhoneBook      string            // Address book file
+	Profiles       string            // Stored profiles file
+	Dialers        map[string]Dialer // Outbound protocols, by name
+	TelnetPort     uint              // Port for inbound telnet sessions
+	SSHPort        uint              // Port for inbound sshd sessions
+	PrivateKey     string            // SSH host key file
+	SSHUsers       string            // Who may log in over SSH, and how
+	SSHKeys        string            // authorized_keys for inbound SSH
+	SSHOpen        bool              // Without SSHUsers or SSHKeys, let anyone in
+	KnownHosts     string            // Host keys for outbound SSH
+	AllowFrom      string            // Only take calls from these CIDRs
+	DenyFrom       string            // Never take calls from these
+	CallsPerMinute int               // From any one address, 0 == no limit
+	Audio          string            // Where the speaker goes, "" == nowhere
+	SkipTelnet     bool              // Don't start the telnet server
+	SkipSSH        bool              // Don't start the SSH server
+}
+
+// Basic modem state.  Everything from currentConfig to conn is ephemeral.
+type Modem struct {
+	currentConfig int        // Which stored config are we using
+	lastCmd       string     // Last command (for A/ command)
+	lastDialed    string     // Last number dialed (for ATDL)
+	connectSpeed  int        // What speed did we connect at (0 == none), guarded by stateLock
+	rxPace        pacer      // Paces network -> DTE to connectSpeed
+	txPace        pacer      // Paces DTE -> network to connectSpeed
+	conn          Connection // Current call, guarded by stateLock
+
+	conf         Config
+	registers    *Registers
+	phonebook    *Phonebook
+	profiles     *storedProfiles
+	serial       *serialPort
+	pins         Pins
+	log          *log.Logger
+	settings     Settings
+	dialers      map[string]Dialer
+	callChannel  chan Connection
+	timer        *time.Ticker
+	escSequence  [3]byte
+	lastRingTime time.Time
+	line         int      // Line number in the bank, from 1
+	bank         *Bank    // Which owns the listeners
+	speaker      *speaker // nil if there's nowhere to play sounds
+
+	stateLock    sync.Mutex
+	state        lineState     // See state.go
+	stateHooks   []stateHook   // Called on every change of state
+	stateChanges []stateChange // Waiting for the hooks
+	hooksRunning bool          // Someone's calling the hooks
+	dialing      *outboundCall // Call being dialed, if any
+	claimed      Connection    // Call the hunt group's ringing us for
+}
+
+// Build a modem talking to the DTE on dte.  Nothing happens until Run()
+// is called.
+func New(dte io.ReadWriter, pins Pins, log *log.Logger, s Settings) *Modem {
+	var m Modem
+
+	m.log = log
+	m.pins = pins
+	m.settings = s
+	if m.settings.Profiles == "" {
+		m.settings.Profiles = __PROFILES_FILE
+	}
+	if m.settings.KnownHosts == "" {
+		m.settings.KnownHosts = __KNOWN_HOSTS_FILE
+	}
+
+	m.dialers = make(map[string]Dialer)
+	if s.Dialers == nil {
+		s.Dialers = DefaultDialers(m.settings)
+	}
+	for proto, d := range s.Dialers {
+		m.dialers[strings.ToUpper(proto)] = d
+	}
+
+	// Setup the GPIO and serial port hardware
+	m.pins.Setup()
+	m.serial = newSerialPort(&m, dte)
+	m.speaker = newSpeaker(s.Audio, log)
+
+	// Setup modem inital state
+	m.registers = NewRegisters()
+	m.callChannel = make(chan Connection)
+	m.defaultStateHooks()
+	m.factoryReset()
+
+	return &m
+}
+
+// Read register n.  DTE drivers use this for things like the
+// backspace and CR characters.
+func (m *Modem) Register(n int) byte {
+	return m.registers.Read(n)
+}
+
+// Boot the modem.  Never returns.  Inbound calls only arrive if the
+// modem is part of a Bank.
+func (m *Modem) Run() {
+	// Setup the "hardware"
+	m.setupHW()
+
+	// Handle inbound/outbound comms
+	go m.serial.getChars()
+	go m.handleCalls()
+
+	time.Sleep(500 * time.Millisecond)
+
+	// Tell user & DTE we're ready
+	m.pins.RaiseDSR()
+	m.pins.RaiseCTS()
+	m.log.Print("Modem Ready")
+	m.prstatus(OK)
+
+	m.handleSerial() // never returns
+}
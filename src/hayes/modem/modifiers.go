@@ -0,0 +1,118 @@
+package modem
+
+import (
+	"context"
+	"time"
+	"unicode"
+)
+
+// Dial modifiers: everything in a phone number that isn't a digit.  The
+// modem acts on them as it dials, taking as long about it as a real one
+// would:
+//
+//	,	Pause for S8 seconds
+//	W	Wait for a second dial tone.  There's no line to listen to,
+//		so this is S6 seconds, as when blind dialing.
+//	@	Wait for quiet answer: a ring, then five seconds of silence.
+//		NO ANSWER if S7 runs out first, as it counts from the
+//		start of dialing.
+//	!	Hook flash
+//	T, P	Tone or pulse dial what follows
+//	R	Reverse originate.  Not supported: the network has no
+//		answer mode, so it's ignored and the call goes out as usual.
+//	^	Send calling tone while waiting for an answer
+//	;	Back to command mode once connected (see dial())
+//
+// Spaces are ignored.  Digits only take time when the speaker is on to
+// hear them.  Blind dialing (ATX0, X1 and X3) waits S6 seconds before
+// the first digit.
+
+const (
+	__HOOK_FLASH   = 500 * time.Millisecond
+	__QUIET_ANSWER = 5 * time.Second
+)
+
+// How the call goes once it's dialed
+type dialOptions struct {
+	callingTone bool
+}
+
+// Dial number, then call whatever it is in the address book
+func (m *Modem) dialModified(ctx context.Context, pulse bool,
+	number string) (Connection, error) {
+
+	bare, opts, err := m.dialOut(ctx, pulse, number)
+	if err != nil {
+		return nil, err
+	}
+	m.ringing(opts.callingTone)
+	return m.dialNumber(ctx, bare)
+}
+
+// Act on the modifiers in number, in order, and return what's left.
+// Stops if ctx is done.
+func (m *Modem) dialOut(ctx context.Context, pulse bool,
+	number string) (string, dialOptions, error) {
+
+	var opts dialOptions
+	var bare, digits []rune
+	dial := func() { // The digits since the last modifier
+		m.digitSounds(ctx, pulse, string(digits))
+		digits = nil
+	}
+
+	var err error
+	if m.conf.blindDial {
+		err = m.pause(ctx, dialTone, m.seconds(REG_BLIND_DIAL_WAIT))
+	} else {
+		m.hear(ctx, timedSound{dialTone, time.Second})
+	}
+	for _, r := range number {
+		if err == nil { // Digits don't stop for ctx, so check it here
+			err = ctx.Err()
+		}
+		if err != nil {
+			return "", opts, err
+		}
+
+		switch unicode.ToUpper(r) {
+		case ',':
+			dial()
+			err = m.pause(ctx, silence, m.seconds(REG_COMMA_DELAY))
+		case 'W':
+			dial()
+			err = m.pause(ctx, dialTone, m.seconds(REG_BLIND_DIAL_WAIT))
+		case '@':
+			dial()
+			err = m.pause(ctx, ringback, 2*time.Second) // One ring
+			if err == nil {
+				err = m.pause(ctx, silence, __QUIET_ANSWER)
+			}
+		case '!':
+			dial()
+			m.log.Print("Hook flash")
+			err = m.pause(ctx, silence, __HOOK_FLASH)
+		case 'T', 'P':
+			dial()
+			pulse = unicode.ToUpper(r) == 'P'
+		case 'R':
+			m.log.Print("Reverse originate isn't supported, ignoring it")
+		case '^':
+			opts.callingTone = true
+		case ' ', ';':
+		default:
+			bare = append(bare, r)
+			digits = append(digits, r)
+		}
+	}
+	if err != nil {
+		return "", opts, err
+	}
+	dial()
+	return string(bare), opts, ctx.Err()
+}
+
+// The value of register reg, as seconds
+func (m *Modem) seconds(reg int) time.Duration {
+	return time.Duration(m.registers.Read(reg)) * time.Second
+}
@@ -1,4 +1,4 @@
-package main
+package modem
 
 import (
 	"fmt"
@@ -6,7 +6,7 @@ import (
 )
 
 // Helper function to parse non-complex AT commands (everthing except ATS.., ATD...)
-func parse(cmd string, opts string) (string, int, error) {
+func (m *Modem) parse(cmd string, opts string) (string, int, error) {
 
 	cmd = strings.ToUpper(cmd)
 	if len(cmd) == 1 {
@@ -17,8 +17,8 @@ func parse(cmd string, opts string) (string, int, error) {
 		return cmd[:2], 2, nil
 	}
 
-	logger.Printf("Bad command: %s", cmd)
-	return "", 0, fmt.Errorf("Bad command: %s", cmd)
+	m.log.Printf("Bad command: %s", redact(cmd))
+	return "", 0, fmt.Errorf("Bad command: %s", redact(cmd))
 }
 
 // ATS...
@@ -67,7 +67,7 @@ func parseRegisters(cmd string) (string, int, error) {
 }
 
 // parse AT&...
-func parseAmpersand(cmdstr string) (string, int, error) {
+func (m *Modem) parseAmpersand(cmdstr string) (string, int, error) {
 	var opts string
 
 	c := strings.ToUpper(cmdstr[1:2])[0]
@@ -101,28 +101,82 @@ func parseAmpersand(cmdstr string) (string, int, error) {
 		case 'z':
 			_, err = fmt.Sscanf(cmdstr, "&z%d=%s", &idx, &str)
 		default:
-			err = fmt.Errorf("Badly formated &Z command: %s", cmdstr)
+			err = fmt.Errorf("Badly formated &Z command: %s",
+				redact(cmdstr))
 		}
 
 		if err != nil {
-			logger.Print("ERROR: ", err)
+			m.log.Print("ERROR: ", err)
 			return "", 0, err
 		}
 		s := fmt.Sprintf("&Z%d=%s", idx, str)
 		return s, len(s), nil
 	default:
-		logger.Printf("Unknown &cmd: %s", cmdstr)
+		m.log.Printf("Unknown &cmd: %s", redact(cmdstr))
 		return "", 0, ERROR
 	}
 
-	s, i, err := parse(cmdstr[1:], opts)
+	s, i, err := m.parse(cmdstr[1:], opts)
 	s = "&" + s
 	i++
 	return s, i, err
 }
 
+// AT%... extensions: %K (SSH host keys) and %T (terminal type).  %x,
+// %x? or %x=value, where the value is the rest of the line.
+func parsePercent(cmd string) (string, int, error) {
+	if len(cmd) < 2 || !strings.ContainsAny(cmd[1:2], "KkTt") {
+		return "", 0, fmt.Errorf("Bad command: %s", cmd)
+	}
+	c := "%" + strings.ToUpper(cmd[1:2])
+	switch {
+	case len(cmd) == 2:
+		return c, 2, nil
+	case cmd[2] == '?':
+		return c + "?", 3, nil
+	case cmd[2] == '=' && len(cmd) > 3:
+		return c + "=" + cmd[3:], len(cmd), nil
+	}
+	return "", 0, fmt.Errorf("Bad command: %s", cmd)
+}
+
+// AT+... and AT#... extended commands.  Just caller ID for now.
+var extendedCommands = []string{"+VCID", "#CID"}
+
+// name=n, name? or name=?, optionally followed by a ';' to separate it
+// from the next command
+func parseExtended(cmd string) (string, int, error) {
+	c := strings.ToUpper(cmd)
+	for _, name := range extendedCommands {
+		if !strings.HasPrefix(c, name) {
+			continue
+		}
+		rest := c[len(name):]
+
+		var s string
+		switch {
+		case strings.HasPrefix(rest, "=?"):
+			s = name + "=?"
+		case strings.HasPrefix(rest, "?"):
+			s = name + "?"
+		case len(rest) > 1 && rest[0] == '=' &&
+			rest[1] >= '0' && rest[1] <= '9':
+			s = name + rest[:2]
+		default:
+			return "", 0, fmt.Errorf("Bad command: %s", cmd)
+		}
+
+		i := len(s)
+		if strings.HasPrefix(c[i:], ";") {
+			i++
+		}
+		return s, i, nil
+	}
+	return "", 0, fmt.Errorf("Bad command: %s", cmd)
+}
+
 // +++
-func parseCommand(cmdstring string) ([]string, error) {
+func (m *Modem) parseCommand(cmdstring string) ([]string, error) {
 	var commands []string
 	var s, opts, cmd string
 	var i, c int
@@ -141,16 +195,16 @@ func parseCommand(cmdstring string) ([]string, error) {
 	// in the extended dial command (ATDE, specifically).
 
 	if len(cmdstring) < 2 {
-		logger.Print("Cmd too short: ", cmdstring)
+		m.log.Print("Cmd too short: ", redact(cmdstring))
 		return nil, ERROR
 	}
 
 	if strings.ToUpper(cmdstring[:2]) != "AT" {
-		logger.Print("Malformed command: ", cmdstring)
+		m.log.Print("Malformed command: ", redact(cmdstring))
 		return nil, ERROR
 	}
 
-	logger.Printf("command: %s", cmdstring)
+	m.log.Printf("command: %s", redact(cmdstring))
 
 	cmd = cmdstring[2:] // Skip the 'AT'
 	c = 0
@@ -169,41 +223,45 @@ func parseCommand(cmdstring string) ([]string, error) {
 		case 'S':
 			s, i, err = parseRegisters(cmd[c:])
 		case '*': // Custom debug registers
-			s, i, err = parseDebug(cmd[c:])
+			s, i, err = m.parseDebug(cmd[c:])
 		case '&':
-			s, i, err = parseAmpersand(cmd[c:])
+			s, i, err = m.parseAmpersand(cmd[c:])
+		case '%':
+			s, i, err = parsePercent(cmd[c:])
+		case '+', '#':
+			s, i, err = parseExtended(cmd[c:])
 		case 'A', '!':
 			opts = "0"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'E', 'H', 'Q', 'V', 'Z':
 			opts = "01"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'M', 'W':
 			opts = "012"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'L':
 			opts = "0123"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'O':
 			opts = "O"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'X':
 			opts = "01234567"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'I':
 			opts = "012345"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 
 		// faked out commands
 		case 'Y', 'C':
 			opts = "01"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 		case 'N', 'B':
 			opts = "012345"
-			s, i, err = parse(cmd[c:], opts)
+			s, i, err = m.parse(cmd[c:], opts)
 
 		default:
-			logger.Printf("Unknown command: %s", cmd)
+			m.log.Printf("Unknown command: %s", redact(cmd))
 			return nil, ERROR
 		}
 
@@ -214,27 +272,57 @@ func parseCommand(cmdstring string) ([]string, error) {
 		c += i
 	}
 
-	logger.Printf("Command array: %+v", commands)
+	logged := make([]string, len(commands))
+	for i, c := range commands {
+		logged[i] = redact(c)
+	}
+	m.log.Printf("Command array: %+v", logged)
 
 	return commands, nil
 }
 
-func runCommand(cmdstring string) error {
+// A command string fit for the log.  ATDE and AT&Z take the rest of
+// the line, and any password or key passphrase in it is starred out.
+func redact(cmd string) string {
+	u := strings.ToUpper(cmd)
+	i, skip := strings.Index(u, "&Z"), 4 // &Zn=phone|host|proto|user|...
+	if i == -1 {
+		i, skip = strings.Index(u, "DE"), 2 // DEhost|user|...
+	}
+	if i == -1 {
+		return cmd
+	}
+
+	end := len(cmd)
+	if strings.HasSuffix(cmd, ";") { // ATDE...; isn't part of the password
+		end--
+	}
+	f := strings.Split(cmd[i:end], "|")
+	for j := skip; j < len(f); j++ {
+		var e PhonebookEntry
+		if sshOptions(&e, f[j:j+1]); e.Password != "" {
+			f[j] = "****"
+		}
+	}
+	return cmd[:i] + strings.Join(f, "|") + cmd[end:]
+}
+
+func (m *Modem) runCommand(cmdstring string) error {
 	var err error
 	if strings.ToUpper(cmdstring) == "AT" {
 		m.lastCmd = "AT"
 		return OK
 	}
 
-	commands, err := parseCommand(cmdstring)
+	commands, err := m.parseCommand(cmdstring)
 	if err != nil {
 		return err
 	}
 
-	err = processCommands(commands)
+	err = m.processCommands(commands)
 
-	if err == OK || err == CONNECT {
-		logger.Printf("Saving command string '%s'", cmdstring)
+	if err == OK || err == CONNECT || err == errPending {
+		m.log.Printf("Saving command string '%s'", redact(cmdstring))
 		m.lastCmd = cmdstring
 	}
 	return err
@@ -0,0 +1,82 @@
+package modem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"ATDT5551234", "ATDT5551234"},
+		{"ATDEhost|user|s3cret", "ATDEhost|user|****"},
+		{"ATDEhost|user", "ATDEhost|user"},
+		{"atdehost|user|s3cret;", "atdehost|user|****;"},
+		{"ATE0DEhost|user|s3cret", "ATE0DEhost|user|****"},
+		{"ATDEdevhost|de|s3cret", "ATDEdevhost|de|****"},
+		{"ATDEhost|user|key=id_rsa|s3cret|cmd=ls",
+			"ATDEhost|user|key=id_rsa|****|cmd=ls"},
+		{"ATDEhost|user|agent|subsystem=sftp",
+			"ATDEhost|user|agent|subsystem=sftp"},
+		{"DEhost|user|s3cret", "DEhost|user|****"},
+		{"AT&Z1=5551234|host|SSH|user|s3cret",
+			"AT&Z1=5551234|host|SSH|user|****"},
+		{"at&z1=5551234|host|SSH|user|key=k|s3cret",
+			"at&z1=5551234|host|SSH|user|key=k|****"},
+		{"&Z1=5551234|host|TELNET|user|", "&Z1=5551234|host|TELNET|user|"},
+		{"ATDHdeadbeef.example.com", "ATDHdeadbeef.example.com"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Whatever's done with a command, the password doesn't end up in the
+// log
+func TestCommandLogging(t *testing.T) {
+	const secret = "s3cret"
+
+	file := filepath.Join(t.TempDir(), "addressbook.json")
+	ioutil.WriteFile(file, []byte("{}"), 0600)
+
+	var logged bytes.Buffer
+	m := &Modem{log: log.New(&logged, "", 0),
+		dialers: map[string]Dialer{"SSH": nil}}
+	m.phonebook = NewPhonebook(file, m.log)
+	if err := m.phonebook.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.runCommand("AT&Z1=5551234|host|SSH|user|" +
+		secret); err != OK {
+		t.Errorf("AT&Z: %v", err)
+	}
+	for _, cmd := range []string{
+		"ATDEhost|user|" + secret,
+		"ATE0DEhost|user|key=id_rsa|" + secret + "|cmd=ls",
+		"AT&z1=5551234|host|SSH|user|" + secret,
+		"AT&Z1|host|SSH|user|" + secret, // Malformed
+		"ATQ9DEhost|user|" + secret,     // Bad command
+		"ATU0DEhost|user|" + secret,     // Unknown command
+		"XXDEhost|user|" + secret,       // Not an AT command
+	} {
+		m.parseCommand(cmd)
+	}
+
+	if logged.Len() == 0 {
+		t.Fatal("nothing logged")
+	}
+	if strings.Contains(logged.String(), secret) {
+		t.Errorf("password in the log:\n%s", logged.String())
+	}
+	if !strings.Contains(logged.String(), "host|user|****") {
+		t.Errorf("commands not logged:\n%s", logged.String())
+	}
+}
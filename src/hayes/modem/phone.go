@@ -0,0 +1,163 @@
+package modem
+
+import (
+	"time"
+)
+
+// Simulate the phone line
+
+// How many rings before giving up
+const __MAX_RINGS = 10
+
+// ATH0: go on hook, dropping the call if there is one, or turning away
+// one that's ringing.  Returns NO_CARRIER if a call was dropped, OK if
+// not, and ERROR if someone else is hanging up already.
+func (m *Modem) hangup() error {
+	var ret error = OK
+
+	if m.abandonCall() {
+		ret = NO_CARRIER
+	}
+
+	m.stateLock.Lock()
+	from, ok := m.changeState(HANGING_UP)
+	conn := m.conn
+	m.unlockState()
+	switch {
+	case ok:
+	case from == HANGING_UP: // Someone else is
+		return ERROR
+	default: // On hook already
+		return ret
+	}
+
+	m.pins.LowerDSR()
+
+	// It's OK to hang up the phone when there's no active network connection.
+	// But if there is, close it.
+	if connected(from) && conn != nil {
+		m.log.Printf("Hanging up on active connection (remote %s)",
+			conn.RemoteAddr())
+		conn.Close()
+		ret = NO_CARRIER
+	}
+
+	m.clearLineRate()
+	m.pins.LED(HS_LED, false)
+
+       	if err := m.serial.Flush(); err != nil {
+		m.log.Printf("serial.Flush(): %s", err)
+	}
+
+	m.setState(IDLE)
+	return ret
+}
+
+// ATH1.  Picking up a ringing line answers it.
+// Note that this will execute in a different context than answerIncoming()
+func (m *Modem) pickup() error {
+	if m.inState(RINGING) {
+		return m.answer()
+	}
+	if m.offHook() || m.transition(IDLE, OFF_HOOK) {
+		return OK
+	}
+	return ERROR
+}
+
+// Answer an incomming call.  id is who's calling, if caller ID is on.
+func (m *Modem) answerIncomming(conn Connection, id *callerID) bool {
+	const __DELAY_MS = 20
+
+	zero := make([]byte, 1)
+
+	r := m.registers
+	for i := 0; i < __MAX_RINGS; i++ {
+		m.lastRingTime = time.Now()
+		conn.Write([]byte("Ringing...\n\r"))
+		m.log.Print("Ringing")
+		if !m.inState(RINGING) { // computer has issued 'ATA'
+			goto picked_up
+		}
+
+		// Simulate the "2-4" pattern for POTS ring signal (2
+		// seconds of high voltage ring signal, 4 seconds
+		// of silence)
+
+		// Ring for 2s
+		d := 0
+		m.pins.RaiseRI()
+		for d < 2000 {
+			if _, err := conn.Write(zero); err != nil {
+				goto no_answer
+			}
+			time.Sleep(__DELAY_MS * time.Millisecond)
+			d += __DELAY_MS
+			if !m.inState(RINGING) { // computer has issued 'ATA'
+				goto picked_up
+			}
+		}
+		m.pins.LowerRI()
+
+		// By verification, the Hayes Ultra 96 displays the
+		// "RING" text /after/ the RI signal is lowered.  Do
+		// this here so we behave the same.
+		m.serial.Println(m.result(RING.(*MError)))
+
+		// If Auto Answer is enabled and we've exceeded the
+		// configured number of rings to wait before
+		// answering, answer the call.  We do this here before
+		// the 4s delay as I think it feels more correct.
+		ringCount := r.Inc(REG_RING_COUNT)
+		aaCount := r.Read(REG_AUTO_ANSWER)
+		if aaCount > 0 {
+			if ringCount >= aaCount {
+				m.log.Print("Auto answering")
+				m.answer()
+			}
+		}
+
+		// Caller ID goes out between the first and second rings, so
+		// it needs S0 to be 0 or at least 2
+		if i == 0 && id != nil && m.inState(RINGING) {
+			m.sendCallerID(*id)
+		}
+
+		// Silence for 4s
+		d = 0
+		for d < 4000 {
+			// Test for closed connection
+			if _, err := conn.Write(zero); err != nil {
+				goto no_answer
+			}
+
+			time.Sleep(__DELAY_MS * time.Millisecond)
+			d += __DELAY_MS
+			if !m.inState(RINGING) { // computer has issued 'ATA'
+				goto picked_up
+			}
+		}
+	}
+
+picked_up:
+	if m.carrier() {
+		goto answered
+	}
+	// Otherwise it was picked up and put down again
+
+no_answer:
+	// At this point we've not answered and have timed out, or the
+	// caller hung up before we answered.
+	m.log.Print("No answer")
+	conn.Write([]byte("No answer, closing connection\n\r"))
+	m.pins.LowerRI()
+	return false
+
+answered:
+	// if we're here, the computer answered.
+	m.log.Print("Answered")
+	conn.Write([]byte("Answered\n\r"))
+	m.registers.Write(REG_RING_COUNT, 0)
+	m.pins.LowerRI()
+	return true
+}
@@ -1,25 +1,49 @@
-package main
+package modem
 
 import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"sort"
 	"strings"
 )
 
 type Phonebook struct {
-	entries  map[int]pb_host
+	entries  map[int]PhonebookEntry
 	filename string
 	log      *log.Logger
 }
-type pb_host struct {
+
+// One address book entry.  Also describes ad-hoc calls (ATDH, ATDE)
+type PhonebookEntry struct {
 	Phone    string `json:"Phone"`
 	Host     string `json:"Host"`
 	Protocol string `json:"Protocol"`
 	Username string `json:"Username"`
 	Password string `json:"Password"`
+
+	// Other ways to log in to SSH hosts.  Paths can use $VARIABLES
+	// (eg, "$SSH_AUTH_SOCK").
+	KeyFile string `json:"KeyFile,omitempty"` // Private key
+	Agent   string `json:"Agent,omitempty"`   // ssh-agent socket
+
+	// What to run on SSH hosts instead of a login shell
+	Command   string `json:"Command,omitempty"`
+	Subsystem string `json:"Subsystem,omitempty"`
+
+	// What the line is like.  Zero values mean the modem's defaults
+	// (S37 for speed) and a perfect line.
+	Speed    int     `json:"Speed,omitempty"`    // bps
+	TermType string  `json:"TermType,omitempty"` // Terminal we claim to be
+	Cols     int     `json:"Cols,omitempty"`     // Screen width
+	Rows     int     `json:"Rows,omitempty"`     // and height
+	Latency  int     `json:"Latency,omitempty"`  // ms, network -> DTE
+	Noise    float64 `json:"Noise,omitempty"`    // Chance a byte is hit
+
+	// Turn away calls from this host
+	Blacklisted bool `json:"Blacklisted,omitempty"`
 }
 
 func NewPhonebook(filename string, log *log.Logger) *Phonebook {
@@ -43,9 +67,33 @@ func (p *Phonebook) Load() error {
 		return err
 	}
 
+	for i, e := range p.entries {
+		p.entries[i] = p.checkLine(i, e)
+	}
 	return nil
 }
 
+// Bring entry i's line into range: a speed we can report in a CONNECT,
+// and noise that's a chance.
+func (p *Phonebook) checkLine(i int, e PhonebookEntry) PhonebookEntry {
+	if e.Speed < 0 {
+		p.log.Printf("Entry %d: speed %d, using the S37 default", i,
+			e.Speed)
+		e.Speed = 0
+	}
+	if e.Speed > 0 && knownSpeed(e.Speed) != e.Speed {
+		p.log.Printf("Entry %d: no such speed as %d, using %d", i,
+			e.Speed, knownSpeed(e.Speed))
+		e.Speed = knownSpeed(e.Speed)
+	}
+	if e.Noise < 0 || e.Noise > 1 {
+		noise := math.Max(0, math.Min(e.Noise, 1))
+		p.log.Printf("Entry %d: noise %g, using %g", i, e.Noise, noise)
+		e.Noise = noise
+	}
+	return e
+}
+
 func (p *Phonebook) Write() error {
 	b, err := json.MarshalIndent(p.entries, "", "\t")
 	if err != nil {
@@ -121,23 +169,23 @@ func sanitizeNumber(n string) (string, error) {
 	return strings.Map(check, n), nil
 }
 
-func (p *Phonebook) Lookup(number string) (string, string, string, string, error) {
+func (p *Phonebook) Lookup(number string) (PhonebookEntry, error) {
 	if !isValidPhoneNumber(number) {
-		return "", "", "", "",
+		return PhonebookEntry{},
 			fmt.Errorf("Invalid phone number '%s'", number)
 	}
 	sanitized_index, err := sanitizeNumber(number)
 	if err != nil {
-		return "", "", "", "", err
+		return PhonebookEntry{}, err
 	}
 	for _, h := range p.entries {
 		sanitized_n, _ := sanitizeNumber(h.Phone)
 		if sanitized_index == sanitized_n {
-			return h.Host, h.Protocol, h.Username, h.Password, nil
+			return h, nil
 		}
 	}
 	err = fmt.Errorf("Number '%s' not in phone book", number)
-	return "", "", "", "", err
+	return PhonebookEntry{}, err
 }
 
 func (p *Phonebook) LookupStoredNumber(n int) (string, error) {
@@ -149,21 +197,48 @@ func (p *Phonebook) LookupStoredNumber(n int) (string, error) {
 }
 
 // Returns phone|host|protocol|username|password
-func splitAmperZ(cmd string) (string, string, string, string, string, error) {
+// phone|host|protocol|username|password, then optional SSH credentials
+func splitAmperZ(cmd string) (PhonebookEntry, error) {
 	s := strings.Split(cmd, "|")
-	if len(s) != 5 {
-		return "", "", "", "", "", fmt.Errorf("Malformated AT&Z command")
+	if len(s) < 5 {
+		return PhonebookEntry{}, fmt.Errorf("Malformated AT&Z command")
+	}
+	e := PhonebookEntry{Phone: s[0], Host: s[1], Protocol: s[2],
+		Username: s[3]}
+	sshOptions(&e, s[4:])
+	return e, nil
+}
+
+// Sort out the password, "key=file", "agent", "agent=socket",
+// "cmd=command" and "subsystem=name" fields at the end of an ATDE or
+// AT&Z command
+func sshOptions(e *PhonebookEntry, fields []string) {
+	for _, f := range fields {
+		switch {
+		case strings.HasPrefix(f, "cmd="):
+			e.Command = f[len("cmd="):]
+		case strings.HasPrefix(f, "subsystem="):
+			e.Subsystem = f[len("subsystem="):]
+		case strings.HasPrefix(f, "key="):
+			e.KeyFile = f[len("key="):]
+		case f == "agent":
+			e.Agent = "$SSH_AUTH_SOCK"
+		case strings.HasPrefix(f, "agent="):
+			e.Agent = f[len("agent="):]
+		default:
+			e.Password = f
+		}
 	}
-	return s[0], s[1], s[2], s[3], s[4], nil
 }
 
-func (p *Phonebook) Add(pos int, phone string) error {
-	phone, host, proto, username, pw, err := splitAmperZ(phone)
+func (p *Phonebook) Add(pos int, phone string, supported func(string) bool) error {
+	e, err := splitAmperZ(phone)
 	if err != nil {
 		return err
 	}
+	phone, proto := e.Phone, e.Protocol
 
-	if !supportedProtocol(proto) {
+	if !supported(proto) {
 		return fmt.Errorf("Unsupported protocol '%s'", proto)
 	}
 	if !isValidPhoneNumber(phone) {
@@ -176,11 +251,11 @@ func (p *Phonebook) Add(pos int, phone string) error {
 		return fmt.Errorf("Number alreasy exists at position %d in phonebook", pos)
 	}
 
-	if _, _, _, _, err = p.Lookup(phone); err == nil {
+	if _, err = p.Lookup(phone); err == nil {
 		return fmt.Errorf("Number already exisits at another position in phonebook")
 	}
 
-	p.entries[pos] = pb_host{host, phone, proto, username, pw}
+	p.entries[pos] = e
 	p.Write()
 	return nil
 }
@@ -0,0 +1,52 @@
+package modem
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// Whatever the address book says, calls pace at a speed the CONNECT
+// result can report, over a line whose noise is a chance
+func TestPhonebookLineChecks(t *testing.T) {
+	tests := []struct {
+		speed, wantSpeed int
+		noise, wantNoise float64
+	}{
+		{0, 0, 0, 0},
+		{2400, 2400, 0.01, 0.01},
+		{115200, 115200, 1, 1},
+		{28800, 19200, 0, 0},
+		{1000000, 115200, 0, 0},
+		{110, 300, 0, 0},
+		{-9600, 0, -0.5, 0},
+		{9600, 9600, 50, 1},
+	}
+
+	entries := make(map[int]PhonebookEntry)
+	for i, tt := range tests {
+		entries[i] = PhonebookEntry{Host: "host", Protocol: "TELNET",
+			Speed: tt.speed, Noise: tt.noise}
+	}
+	b, _ := json.Marshal(entries)
+	file := filepath.Join(t.TempDir(), "addressbook.json")
+	ioutil.WriteFile(file, b, 0600)
+
+	p := NewPhonebook(file, log.New(ioutil.Discard, "", 0))
+	if err := p.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		e := p.entries[i]
+		if e.Speed != tt.wantSpeed || e.Noise != tt.wantNoise {
+			t.Errorf("speed %d, noise %g: got %d, %g, want %d, %g",
+				tt.speed, tt.noise, e.Speed, e.Noise, tt.wantSpeed,
+				tt.wantNoise)
+		}
+		if e.Speed != 0 && speedToResult(e.Speed) == CONNECT {
+			t.Errorf("speed %d: reports a plain CONNECT", e.Speed)
+		}
+	}
+}
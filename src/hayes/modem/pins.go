@@ -0,0 +1,45 @@
+package modem
+
+// The modem's RS-232 control lines and front panel LEDs.  On a
+// Raspberry Pi these are GPIO pins, everywhere else they're simulated.
+type Pins interface {
+	Setup()
+	Clear()          // Drop everything, eg. on exit
+	String() string  // For AT*
+	LEDTest(rounds int)
+	LED(led int, on bool)
+
+	// Outputs
+	RaiseRI()
+	LowerRI()
+	ReadRI() bool
+	RaiseCD()
+	LowerCD()
+	ReadCD() bool
+	RaiseDSR()
+	LowerDSR()
+	ReadDSR() bool
+	RaiseCTS()
+	LowerCTS()
+	ReadCTS() bool
+
+	// Inputs
+	ReadDTR() bool
+	ReadRTS() bool
+}
+
+// LEDs.  RI, CD, DSR (MR) and CTS (CS) LEDs follow their pins.
+const (
+	HS_LED = iota // Connected at High speed (conf.speed > 14400)
+	AA_LED        // Auto Answer configured (conf.r[0] > 0)
+	TR_LED        // Terminal Ready (turn on if read(DTR) is high)
+	OH_LED        // Is the modem off hook (m.offHook() == true)
+	RD_LED        // Receive Data
+	SD_LED        // Send Data
+	RI_LED        // Ring Indicator
+	CD_LED        // Carrier Detect
+	MR_LED        // Modem Ready (DSR)
+	CS_LED        // Clear To Send
+
+	_LED_LEN // This needs to be last in the const list
+)
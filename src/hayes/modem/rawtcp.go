@@ -0,0 +1,84 @@
+package modem
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Implements connection for outbound plain TCP sockets: no telnet
+// processing, every byte goes through untouched.
+type rawReadWriteCloser struct {
+	sent, recv uint64 // Atomic, see Connection
+	c          net.Conn
+	log        *log.Logger
+}
+
+func (m *rawReadWriteCloser) String() string {
+	sent, recv := m.Stats()
+	return fmt.Sprintf("Outbound raw TCP to %s, sent %s, received %s",
+		m.c.RemoteAddr(),
+		bytefmt.ByteSize(sent), bytefmt.ByteSize(recv))
+}
+
+func (m *rawReadWriteCloser) Read(p []byte) (int, error) {
+	i, err := m.c.Read(p)
+	atomic.AddUint64(&m.recv, uint64(i))
+	return i, err
+}
+
+func (m *rawReadWriteCloser) Write(p []byte) (int, error) {
+	i, err := m.c.Write(p)
+	if err != nil {
+		m.log.Print(err)
+	}
+	atomic.AddUint64(&m.sent, uint64(i))
+	return i, err
+}
+
+func (m *rawReadWriteCloser) Close() error {
+	m.log.Printf("Closing TCP connection to %s", m.RemoteAddr())
+	return m.c.Close()
+}
+
+func (m *rawReadWriteCloser) Direction() int {
+	return OUTBOUND
+}
+
+func (m *rawReadWriteCloser) RemoteAddr() net.Addr {
+	return m.c.RemoteAddr()
+}
+
+func (m *rawReadWriteCloser) Stats() (uint64, uint64) {
+	return atomic.LoadUint64(&m.sent), atomic.LoadUint64(&m.recv)
+}
+
+func (m *rawReadWriteCloser) SetDeadline(t time.Time) error {
+	return m.c.SetDeadline(t)
+}
+
+// There's no well known port for a raw socket, so one is required.
+func dialRaw(ctx context.Context, remote string,
+	log *log.Logger) (Connection, error) {
+
+	if _, _, err := net.SplitHostPort(remote); err != nil {
+		log.Printf("Error: %s", err)
+		return nil, err
+	}
+	log.Printf("Connecting to: %s", remote)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", remote)
+	if err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			log.Print("net.Dial: Timed out")
+		}
+		log.Printf("Error: %s", err)
+		return nil, err
+	}
+
+	log.Printf("Connected to %s", conn.RemoteAddr())
+	return &rawReadWriteCloser{c: conn, log: log}, nil
+}
@@ -1,7 +1,8 @@
-package main
+package modem
 
 import (
 	"fmt"
+	"log"
 	"sort"
 	"strconv"
 	"sync"
@@ -69,6 +70,27 @@ const (
 	// If no data transfered in INACTIVITY_TIMER seconds, hangup
 	// and return to command mode.  Default is 0, disabled.
 	REG_INACTIVITY_TIMER = 30
+
+	// Line speed to connect at, see lineSpeeds.  Default is 0
+	// (as fast as possible)
+	REG_LINE_SPEED = 37
+
+	// Vendor extensions, not found on a real modem.
+
+	// Telnet 8-bit clean mode.  0 == only if the remote asks for
+	// TELNET BINARY, 1 == ask for BINARY on connect, 2 == always
+	// 8-bit clean, even if the remote refuses.  Default is 0.
+	REG_TELNET_BINARY = 200
+
+	// Screen size given to telnet and SSH hosts, unless the address
+	// book says otherwise.  0 == 80 columns, 24 rows.
+	REG_TERM_COLS = 201
+	REG_TERM_ROWS = 202
+
+	// The caller's screen size, if their telnet or SSH client told
+	// us.  Read only, 0 == unknown.
+	REG_REMOTE_COLS = 203
+	REG_REMOTE_ROWS = 204
 )
 
 const __NUM_REGS = 256
@@ -91,12 +113,17 @@ func (r *Registers) Reset() {
 	r.Write(REG_ESC_CODE_GUARD_TIME, 50)
 	r.Write(REG_DTR_DETECTION_TIME, 5)
 	r.Write(REG_INACTIVITY_TIMER, 0)
+	r.Write(REG_LINE_SPEED, 0)
+	r.Write(REG_TELNET_BINARY, 0)
+	r.Write(REG_TERM_COLS, 0)
+	r.Write(REG_TERM_ROWS, 0)
+	r.Write(REG_REMOTE_COLS, 0)
+	r.Write(REG_REMOTE_ROWS, 0)
 
 	// These are cosmetic, not functional.
 	r.Write(18, 0)
 	r.Write(26, 1)
 	r.Write(36, 7)
-	r.Write(37, 0)
 	r.Write(38, 20)
 	r.Write(44, 3)
 	r.Write(46, 2)
@@ -109,8 +136,6 @@ func (r *Registers) Reset() {
 	r.Write(110, 2)
 }
 
-var escSequence [3]byte = [3]byte{'+', '+', '+'}
-
 func NewRegisters() *Registers {
 	var r Registers
 
@@ -212,20 +237,58 @@ func (r *Registers) JsonMap() map[string]byte {
 	return s
 }
 
-func registersJsonUnmap(m map[string]byte) *Registers {
+func registersJsonUnmap(m map[string]byte, log *log.Logger) *Registers {
 
 	nr := NewRegisters()
 	for key, val := range m {
 		i, err := strconv.Atoi(key)
 		if err != nil {
-			logger.Printf("Atoi(): %s", err)
+			log.Printf("Atoi(): %s", err)
 			continue
 		}
 		if i < 0 || i > 255 {
-			logger.Printf("Bad register in config: regnum = %d", i)
+			log.Printf("Bad register in config: regnum = %d", i)
+		} else if !validRegister(i, val) {
+			log.Printf("Bad value in config: S%d=%d", i, val)
 		} else {
 			nr.Write(i, val)
 		}
 	}
 	return nr
 }
+
+// Would ATSn= take val for register reg?
+func validRegister(reg int, val byte) bool {
+	switch reg {
+	case REG_BLIND_DIAL_WAIT:
+		return val >= 2
+	case REG_WAIT_FOR_CARRIER_AFTER_DIAL:
+		return val >= 1
+	case REG_COMMA_DELAY:
+		return val <= 65
+	case REG_BS_CH, REG_LF_CH, REG_CR_CH:
+		return val <= 127
+	case REG_LINE_SPEED:
+		_, ok := lineSpeeds[val]
+		return ok || val == 0
+	case REG_TELNET_BINARY:
+		return val <= 2
+	}
+	return true
+}
+
+// Reset r, then set the registers stored in m, as saved by JsonMap().
+// Values ATSn= wouldn't take are left at their defaults.  The registers
+// all change at once, under the lock.
+func (r *Registers) load(m map[string]byte, log *log.Logger) {
+	nr := NewRegisters()
+	nr.Reset()
+	stored := registersJsonUnmap(m, log)
+	for _, f := range stored.activeRegisters() {
+		nr.Write(f, stored.Read(f))
+	}
+
+	r.rlock.Lock()
+	defer r.rlock.Unlock()
+	r.regs = nr.regs
+}
@@ -0,0 +1,44 @@
+package modem
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+// A stored profile only sets what ATSn= would, and leaves the rest at
+// the defaults
+func TestRegistersLoad(t *testing.T) {
+	r := NewRegisters()
+	r.Write(REG_ESC_CH, '!')
+	r.Write(100, 1)
+	r.load(map[string]byte{
+		"7":  0,   // S7=0 would never wait for an answer
+		"37": 4,   // No such speed
+		"3":  200, // Not ASCII
+		"12": 30,
+		"6":  5,
+		"x":  1,
+	}, log.New(ioutil.Discard, "", 0))
+
+	tests := []struct {
+		reg  int
+		want byte
+	}{
+		{REG_WAIT_FOR_CARRIER_AFTER_DIAL, 50},
+		{REG_LINE_SPEED, 0},
+		{REG_CR_CH, '\r'},
+		{REG_ESC_CODE_GUARD_TIME, 30},
+		{REG_BLIND_DIAL_WAIT, 5},
+		{REG_ESC_CH, '+'},
+		{100, 0},
+	}
+	for _, tt := range tests {
+		if got := r.Read(tt.reg); got != tt.want {
+			t.Errorf("S%d = %d, want %d", tt.reg, got, tt.want)
+		}
+	}
+	if r.valid(100) {
+		t.Error("S100 left over from before the load")
+	}
+}
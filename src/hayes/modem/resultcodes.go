@@ -1,4 +1,4 @@
-package main
+package modem
 
 // Command Result codes
 
@@ -72,29 +72,40 @@ func speedToResult(speed int) error {
 }
 
 func (e *MError) Error() string {
+	return e.text
+}
+
+// What the DTE sees for a result code, given the current configuration
+func (m *Modem) result(e *MError) string {
 
-	if conf.quiet {
-		logger.Printf("Quiet mode, status: %s", e)
+	if m.conf.quiet {
+		m.log.Printf("Quiet mode, status: %s", e)
 		return ""
 	}
 
-	if e == CONNECT && conf.connectMsgSpeed {
-		me := speedToResult(m.connectSpeed)
+	if e == CONNECT && m.conf.connectMsgSpeed {
+		me := speedToResult(m.getConnectSpeed())
 		if me != CONNECT {
-			return me.Error()
+			return m.result(me.(*MError))
 		}
 	}
 
-	if e == BUSY && !conf.busyDetect {
-		e = nil
+	// Without the extended result codes, a call that didn't go
+	// through is just NO CARRIER
+	if e == BUSY && !m.conf.busyDetect {
+		e = NO_CARRIER.(*MError)
 	}
 
-	if (e == NO_DIALTONE || e == NO_ANSWER) && !conf.extendedResultCodes {
-		e = nil
+	if e == NO_DIALTONE && (m.conf.blindDial || !m.conf.extendedResultCodes) {
+		e = NO_CARRIER.(*MError)
+	}
+
+	if e == NO_ANSWER && !m.conf.extendedResultCodes {
+		e = NO_CARRIER.(*MError)
 	}
 
 	var s string
-	switch conf.verbose {
+	switch m.conf.verbose {
 	case true:
 		if e != nil {
 			s = fmt.Sprintf("%s", e.text)
@@ -110,28 +121,32 @@ func (e *MError) Error() string {
 	}
 	
 	logentry := fmt.Sprintf("Result Code: %s", s)
-        logger.Print(strings.Replace(logentry, "\n", "", -1))
+        m.log.Print(strings.Replace(logentry, "\n", "", -1))
 
 	return s
 }
 
 // This is needed because nil errors are "OK", but Prinln(OK) can't work.
 // I'm starting to think overloading error as result codes is a massive mistake.
-func prstatus(e error) {
+func (m *Modem) prstatus(e error) {
+	if e == errPending { // Whoever placed or answered the call will say
+		return
+	}
 	time.Sleep(300 * time.Millisecond) // Cosmetic pause...
 	if e == nil {
-		switch conf.verbose {
-		case true:  serial.Println("OK")
-		case false: serial.Println("0")
+		switch m.conf.verbose {
+		case true:  m.serial.Println("OK")
+		case false: m.serial.Println("0")
 		}
 	} else {
 		
 		// If the underlying type isn't MError, log it and print a
 		// generic ERROR
-		if _, ok := e.(*MError); !ok {
-			logger.Printf("Error not MError: %s", e.Error())
-			e = ERROR
+		me, ok := e.(*MError)
+		if !ok {
+			m.log.Printf("Error not MError: %s", e.Error())
+			me = ERROR.(*MError)
 		}
-		serial.Println(e)
+		m.serial.Println(m.result(me))
 	}
 }	
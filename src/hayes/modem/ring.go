@@ -0,0 +1,129 @@
+package modem
+
+import (
+	"io"
+	"sync"
+)
+
+// A fixed size byte FIFO between two goroutines.  Writers block while
+// it's full, which is what pushes back on whoever is feeding it.
+type ringBuffer struct {
+	buf    []byte
+	start  int // Next byte to read
+	length int // Bytes waiting
+	closed bool
+	lock   sync.Mutex
+	cond   *sync.Cond
+	ready  chan struct{} // Poked when there's data to read
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	r := &ringBuffer{buf: make([]byte, size),
+		ready: make(chan struct{}, 1)}
+	r.cond = sync.NewCond(&r.lock)
+	return r
+}
+
+// Must hold r.lock
+func (r *ringBuffer) poke() {
+	if r.length == 0 {
+		return
+	}
+	select {
+	case r.ready <- struct{}{}:
+	default: // Already poked
+	}
+}
+
+// Blocks until all of p is in the buffer, or the buffer is closed
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n := 0
+	for n < len(p) {
+		for r.length == len(r.buf) && !r.closed {
+			r.cond.Wait()
+		}
+		if r.closed {
+			return n, io.ErrClosedPipe
+		}
+
+		end := (r.start + r.length) % len(r.buf)
+		space := len(r.buf) - r.length
+		if end+space > len(r.buf) { // Don't wrap in one copy
+			space = len(r.buf) - end
+		}
+		i := copy(r.buf[end:end+space], p[n:])
+		r.length += i
+		n += i
+		r.poke()
+		r.cond.Broadcast()
+	}
+	return n, nil
+}
+
+// Must hold r.lock
+func (r *ringBuffer) take(p []byte) int {
+	n := 0
+	for n < len(p) && r.length > 0 {
+		end := r.start + r.length
+		if end > len(r.buf) {
+			end = len(r.buf)
+		}
+		i := copy(p[n:], r.buf[r.start:end])
+		r.start = (r.start + i) % len(r.buf)
+		r.length -= i
+		n += i
+	}
+	if n > 0 {
+		r.cond.Broadcast()
+	}
+	r.poke()
+	return n
+}
+
+// Blocks until there's something to read.  io.EOF once the buffer is
+// closed and empty.
+func (r *ringBuffer) Read(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for r.length == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	if r.length == 0 {
+		return 0, io.EOF
+	}
+	return r.take(p), nil
+}
+
+// Whatever's there right now, without blocking
+func (r *ringBuffer) TryRead(p []byte) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.take(p)
+}
+
+func (r *ringBuffer) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.length
+}
+
+// Throw away anything waiting
+func (r *ringBuffer) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.start = 0
+	r.length = 0
+	r.cond.Broadcast()
+}
+
+// No more writes.  Readers get what's left, then io.EOF.
+func (r *ringBuffer) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+}
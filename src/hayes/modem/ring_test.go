@@ -0,0 +1,225 @@
+package modem
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRingBufferWrap(t *testing.T) {
+	type op struct {
+		write string // Write this, or
+		read  int    // read up to this many
+		want  string // and get this
+	}
+	tests := []struct {
+		name string
+		size int
+		ops  []op
+	}{
+		{"fill and empty", 4, []op{
+			{write: "abcd"},
+			{read: 8, want: "abcd"},
+			{read: 8, want: ""},
+		}},
+		{"wrap the write", 8, []op{
+			{write: "abcdef"},
+			{read: 4, want: "abcd"},
+			{write: "ghijk"}, // 2 at the end, 3 at the start
+			{read: 8, want: "efghijk"},
+		}},
+		{"wrap the read", 8, []op{
+			{write: "abcdefg"},
+			{read: 6, want: "abcdef"},
+			{write: "hijkl"},
+			{read: 3, want: "ghi"},
+			{read: 3, want: "jkl"},
+		}},
+		{"full after wrapping", 4, []op{
+			{write: "abc"},
+			{read: 2, want: "ab"},
+			{write: "def"},
+			{read: 4, want: "cdef"},
+		}},
+		{"round and round", 3, []op{
+			{write: "ab"}, {read: 1, want: "a"},
+			{write: "cd"}, {read: 2, want: "bc"},
+			{write: "ef"}, {read: 3, want: "def"},
+			{write: "ghi"}, {read: 3, want: "ghi"},
+		}},
+		{"one byte", 1, []op{
+			{write: "a"}, {read: 5, want: "a"},
+			{write: "b"}, {read: 5, want: "b"},
+		}},
+	}
+
+	for _, tt := range tests {
+		r := newRingBuffer(tt.size)
+		waiting := ""
+		for i, o := range tt.ops {
+			if o.write != "" {
+				if n, err := r.Write([]byte(o.write)); n != len(o.write) ||
+					err != nil {
+					t.Errorf("%s, op %d: Write() = %d, %v", tt.name, i,
+						n, err)
+				}
+				waiting += o.write
+			} else {
+				p := make([]byte, o.read)
+				if got := string(p[:r.TryRead(p)]); got != o.want {
+					t.Errorf("%s, op %d: read %q, want %q", tt.name,
+						i, got, o.want)
+				}
+				waiting = waiting[len(o.want):]
+			}
+			if r.Len() != len(waiting) {
+				t.Errorf("%s, op %d: Len() = %d, want %d", tt.name, i,
+					r.Len(), len(waiting))
+			}
+		}
+	}
+}
+
+// A write bigger than the buffer goes in as the reader makes room, in
+// order
+func TestRingBufferBlocksWhenFull(t *testing.T) {
+	r := newRingBuffer(4)
+	want := "abcdefghijklmnopqrstuvwxyz"
+	go func() {
+		r.Write([]byte(want))
+		r.Close()
+	}()
+
+	var got []byte
+	p := make([]byte, 3)
+	for {
+		n, err := r.Read(p)
+		got = append(got, p[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if r.Len() > 4 {
+			t.Fatalf("%d bytes in a 4 byte buffer", r.Len())
+		}
+	}
+	if string(got) != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+}
+
+func TestRingBufferClose(t *testing.T) {
+	r := newRingBuffer(4)
+	r.Write([]byte("ab"))
+	r.Close()
+
+	p := make([]byte, 4)
+	if n, err := r.Read(p); string(p[:n]) != "ab" || err != nil {
+		t.Errorf("Read() = %q, %v, want what was left", p[:n], err)
+	}
+	if n, err := r.Read(p); n != 0 || err != io.EOF {
+		t.Errorf("Read() = %d, %v, want io.EOF", n, err)
+	}
+	if _, err := r.Write([]byte("c")); err != io.ErrClosedPipe {
+		t.Errorf("Write() after Close() = %v", err)
+	}
+}
+
+func TestRingBufferReady(t *testing.T) {
+	r := newRingBuffer(4)
+	select {
+	case <-r.ready:
+		t.Fatal("ready while empty")
+	default:
+	}
+
+	r.Write([]byte("abc"))
+	<-r.ready
+	r.TryRead(make([]byte, 1)) // Still something left, so poked again
+	select {
+	case <-r.ready:
+	default:
+		t.Error("not ready with 2 bytes waiting")
+	}
+
+	r.Reset()
+	if r.Len() != 0 || r.TryRead(make([]byte, 4)) != 0 {
+		t.Error("not empty after Reset()")
+	}
+}
+
+func TestPacerChunk(t *testing.T) {
+	tests := []struct {
+		speed, max, want int
+	}{
+		{0, 256, 256}, // Unthrottled
+		{300, 256, 1},
+		{1200, 256, 1},
+		{9600, 256, 9},
+		{38400, 256, 38},
+		{115200, 256, 115},
+		{115200, 64, 64},
+	}
+	for _, tt := range tests {
+		var p pacer
+		p.setSpeed(tt.speed)
+		if got := p.chunk(tt.max); got != tt.want {
+			t.Errorf("%d bps: chunk(%d) = %d, want %d", tt.speed, tt.max,
+				got, tt.want)
+		}
+	}
+}
+
+func TestPacerWait(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed int
+		sends []int         // Bytes sent back to back
+		want  time.Duration // How long that should take
+	}{
+		{"unthrottled", 0, []int{100000}, 0},
+		{"9600 bps", 9600, []int{96}, 100 * time.Millisecond},
+		{"adds up", 9600, []int{48, 48, 48, 48}, 200 * time.Millisecond},
+		{"300 bps", 300, []int{3, 3}, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		var p pacer
+		p.setSpeed(tt.speed)
+		start := time.Now()
+		for _, n := range tt.sends {
+			p.wait(n)
+		}
+		took := time.Since(start)
+		if took < tt.want-10*time.Millisecond ||
+			took > tt.want+__PACE_SLACK {
+			t.Errorf("%s: took %s, want %s", tt.name, took, tt.want)
+		}
+	}
+}
+
+// An idle line doesn't bank time to send a burst later
+func TestPacerIdle(t *testing.T) {
+	var p pacer
+	p.setSpeed(9600)
+	p.wait(9)
+	time.Sleep(2 * __PACE_SLACK)
+
+	start := time.Now()
+	p.wait(96)
+	if took := time.Since(start); took < 90*time.Millisecond {
+		t.Errorf("took %s after idling, want 100ms", took)
+	}
+}
+
+// Changing speed mid-call takes effect at once
+func TestPacerSetSpeed(t *testing.T) {
+	var p pacer
+	p.setSpeed(300)
+	p.wait(30) // A second's worth, at 300 bps
+	p.setSpeed(0)
+
+	start := time.Now()
+	p.wait(1000)
+	if took := time.Since(start); took > 10*time.Millisecond {
+		t.Errorf("took %s unthrottled", took)
+	}
+}
@@ -0,0 +1,135 @@
+package modem
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Which inbound calls we take at all.  Shared by every listener in a
+// bank, so a caller's calls on one port count against the others.
+type callScreen struct {
+	allow     []*net.IPNet // If any, callers must be in one
+	deny      []*net.IPNet
+	perMinute int           // Calls from one address, 0 == no limit
+	addresses *addressIndex // For blacklisted entries
+	lock      sync.Mutex
+	calls     map[string][]time.Time // Recent calls, by IP address
+	log       *log.Logger
+}
+
+// allow and deny are comma separated CIDR blocks or addresses
+func newCallScreen(allow, deny string, perMinute int,
+	addresses *addressIndex, log *log.Logger) (*callScreen, error) {
+
+	s := &callScreen{perMinute: perMinute, addresses: addresses,
+		calls: make(map[string][]time.Time), log: log}
+
+	var err error
+	if s.allow, err = parseNets(allow); err != nil {
+		return nil, fmt.Errorf("Bad allow list: %s", err)
+	}
+	if s.deny, err = parseNets(deny); err != nil {
+		return nil, fmt.Errorf("Bad deny list: %s", err)
+	}
+	return s, nil
+}
+
+func parseNets(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, a := range strings.Split(list, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if !strings.Contains(a, "/") { // Just the one address
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("bad address %s", a)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			a = fmt.Sprintf("%s/%d", a, bits)
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func inNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Should we turn away a call from addr?  Logs why if so.  Every call
+// counts towards the rate limit, even those turned away.  Can take a
+// while if the address book has changed, so not in an accept loop.
+func (s *callScreen) reject(addr net.Addr) bool {
+	if s == nil {
+		return false
+	}
+	ip := addrIP(addr)
+	why := s.check(ip)
+	if why != "" {
+		s.log.Printf("Rejecting call from %s: %s", addr, why)
+	}
+	return why != ""
+}
+
+func (s *callScreen) check(ip string) string {
+	if n := s.count(ip); s.perMinute > 0 && n > s.perMinute {
+		return fmt.Sprintf("more than %d calls a minute", s.perMinute)
+	}
+
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return "unknown address"
+	case inNets(parsed, s.deny):
+		return "denied"
+	case len(s.allow) > 0 && !inNets(parsed, s.allow):
+		return "not allowed"
+	}
+
+	if e, ok := s.addresses.blacklisted(ip); ok {
+		return fmt.Sprintf("blacklisted number %s", e.Phone)
+	}
+	return ""
+}
+
+// Note a call from ip, and return how many there have been in the last
+// minute
+func (s *callScreen) count(ip string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for a, calls := range s.calls { // Forget the old ones
+		recent := calls[:0]
+		for _, t := range calls {
+			if time.Since(t) < time.Minute {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(s.calls, a)
+		} else {
+			s.calls[a] = recent
+		}
+	}
+
+	s.calls[ip] = append(s.calls[ip], time.Now())
+	return len(s.calls[ip])
+}
@@ -0,0 +1,268 @@
+package modem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Flow control (AT&K)
+const (
+	FLOW_NONE    = 0
+	FLOW_RTSCTS  = 3
+	FLOW_XONXOFF = 4
+
+	XON  = 0x11
+	XOFF = 0x13
+)
+
+// Bytes from the DTE waiting to go to the network (or be processed as
+// commands).  Past the high water
+// mark we ask the DTE to stop, below the low water mark it can start
+// again.
+const (
+	__TX_BUFFER = 1024
+	__TX_HIGH   = 768
+	__TX_LOW    = 256
+)
+
+// How often to check if the DTE is ready for more data, and how long
+// to wait before sending anyway: a DTE that's gone away (or unplugged
+// with RTS low) mustn't wedge the modem.
+const (
+	__FLOW_POLL    = 10 * time.Millisecond
+	__FLOW_TIMEOUT = 5 * time.Second
+)
+
+// How long to leave the DTE after a read fails, before trying again
+const __DTE_RETRY = time.Second
+
+// The DTE side of the modem: a serial port, a pty, a console, or
+// anything else that can be read and written.
+type serialPort struct {
+	m    *Modem
+	port io.ReadWriter
+	in   *ringBuffer // From the DTE, waiting for handleSerial()
+
+	lock   sync.Mutex
+	flow   int  // Flow control in use: conf.flowControl, as of AT&K
+	xoff   bool // The DTE sent XOFF
+	held   bool // We've asked the DTE to stop sending
+	gaveUp bool // Stopped waiting for the DTE, until it's ready again
+}
+
+// Ports that buffer output, like tarm/serial
+type flusher interface {
+	Flush() error
+}
+
+func newSerialPort(m *Modem, port io.ReadWriter) *serialPort {
+	return &serialPort{m: m, port: port, in: newRingBuffer(__TX_BUFFER)}
+}
+
+func (s *serialPort) Flush() error {
+	p, ok := s.port.(flusher)
+	if !ok {
+		return nil
+	}
+
+	s.m.log.Print("flushing serial port")
+	return p.Flush()
+}
+
+// Is the DTE refusing data?
+func (s *serialPort) stopped() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stopped := false
+	switch s.flow {
+	case FLOW_RTSCTS:
+		stopped = !s.m.pins.ReadRTS()
+	case FLOW_XONXOFF:
+		stopped = s.xoff
+	}
+	if !stopped {
+		s.gaveUp = false
+	}
+	return stopped
+}
+
+// Ask the DTE to stop (or start) sending to us
+func (s *serialPort) hold(on bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.held == on {
+		return
+	}
+	s.held = on
+	s.m.log.Printf("Flow control: holding DTE: %t", on)
+
+	switch s.flow {
+	case FLOW_RTSCTS:
+		if on {
+			s.m.pins.LowerCTS()
+		} else {
+			s.m.pins.RaiseCTS()
+		}
+	case FLOW_XONXOFF:
+		c := byte(XON)
+		if on {
+			c = XOFF
+		}
+		s.port.Write([]byte{c})
+	}
+}
+
+// A byte's been taken from the DTE buffer; let the DTE go again if
+// there's room.
+func (s *serialPort) drained() {
+	if s.in.Len() <= __TX_LOW {
+		s.hold(false)
+	}
+}
+
+// AT&K.  Don't leave the DTE stuck under the old scheme.
+func (s *serialPort) setFlowControl(flow int) {
+	s.hold(false)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.m.conf.flowControl = flow
+	s.flow = flow
+	s.xoff = false
+}
+
+// Is the flow control XON/XOFF?
+func (s *serialPort) xonXoff() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.flow == FLOW_XONXOFF
+}
+
+// Errors that mean there'll never be anything more to read
+func dteGone(err error) bool {
+	return err == io.EOF || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, os.ErrClosed)
+}
+
+func (s *serialPort) Read(p []byte) (int, error) {
+	return s.port.Read(p)
+}
+
+// Must be a goroutine
+func (s *serialPort) getChars() {
+
+	buf := make([]byte, __DTE_BATCH)
+	failing := false
+	for {
+		i, err := s.Read(buf)
+		if err != nil {
+			if i == 0 && dteGone(err) {
+				s.m.log.Print("DTE closed, not reading it any more: ",
+					err)
+				return
+			}
+			if !failing { // Once, not every time round
+				s.m.log.Print("Read(): ", err)
+			}
+			failing = true
+			if i == 0 {
+				time.Sleep(__DTE_RETRY)
+				continue
+			}
+		} else {
+			failing = false
+		}
+
+		// XON/XOFF are for us, not the remote
+		in := buf[:i]
+		if s.xonXoff() {
+			n := 0
+			for _, c := range in {
+				if c == XON || c == XOFF {
+					s.lock.Lock()
+					s.xoff = c == XOFF
+					s.lock.Unlock()
+					continue
+				}
+				in[n] = c
+				n++
+			}
+			in = in[:n]
+		}
+
+		s.in.Write(in) // Blocks when handleSerial() falls behind
+		if s.in.Len() >= __TX_HIGH {
+			s.hold(true)
+		}
+	}
+}
+
+// Blocks while the DTE isn't ready, unless it's dropped DTR or kept us
+// waiting too long
+func (s *serialPort) Write(p []byte) (int, error) {
+	give := time.Now().Add(__FLOW_TIMEOUT)
+	for s.stopped() && !s.waitedEnough(give) {
+		time.Sleep(__FLOW_POLL)
+	}
+	return s.port.Write(p)
+}
+
+// Should Write() stop waiting for the DTE?  Once it has, it doesn't wait
+// again until the DTE's been ready.
+func (s *serialPort) waitedEnough(give time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.gaveUp && (time.Now().After(give) || !s.m.pins.ReadDTR()) {
+		s.m.log.Print("Flow control: DTE not ready, sending anyway")
+		s.gaveUp = true
+	}
+	return s.gaveUp
+}
+
+// Echo a character typed in command mode back to the DTE
+func (s *serialPort) echoByte(p byte) (int, error) {
+	var out []byte
+
+	// map '\n' to '\n\r'
+	switch p {
+	case s.m.registers.Read(REG_CR_CH):
+		out = make([]byte, 2)
+		out[0] = p
+		out[1] = s.m.registers.Read(REG_LF_CH)
+	default:
+		out = make([]byte, 1)
+		out[0] = p
+	}
+
+	return s.Write(out)
+}
+
+func (s *serialPort) Printf(format string, a ...interface{}) error {
+	out := fmt.Sprintf(format, a...)
+	out = strings.Replace(out, "\n", "\n\r", -1)
+	_, err := s.Write([]byte(out))
+	return err
+}
+
+func (s *serialPort) Print(a ...interface{}) error {
+	if a == nil {
+		return nil
+	}
+	return s.Printf("%s", a...)
+}
+
+func (s *serialPort) Println(a ...interface{}) error {
+	if a == nil {
+		return s.Printf("\n")
+	}
+	return s.Printf("%s\n", a...)
+}
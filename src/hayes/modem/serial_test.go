@@ -0,0 +1,287 @@
+package modem
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Pins the test can change under a running modem
+type testPins struct {
+	simPins
+	lock          sync.Mutex
+	rts, dtr, cts bool
+}
+
+func (p *testPins) set(rts, dtr bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.rts, p.dtr = rts, dtr
+}
+
+func (p *testPins) ReadRTS() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.rts
+}
+
+func (p *testPins) ReadDTR() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.dtr
+}
+
+func (p *testPins) RaiseCTS() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.cts = true
+}
+
+func (p *testPins) LowerCTS() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.cts = false
+}
+
+func (p *testPins) ReadCTS() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.cts
+}
+
+// A DTE that types what comes down typed, and keeps what it's sent.
+// Once typed is closed, it's gone for good.
+type fakeDTE struct {
+	typed   chan []byte
+	pending []byte // Typed, and not read yet
+	err     error  // Once, instead of the next thing typed
+	reads   int
+
+	lock sync.Mutex
+	sent bytes.Buffer
+}
+
+func (d *fakeDTE) Read(p []byte) (int, error) {
+	d.reads++
+	if d.err != nil {
+		err := d.err
+		d.err = nil
+		return 0, err
+	}
+	if len(d.pending) == 0 {
+		b, ok := <-d.typed
+		if !ok {
+			return 0, net.ErrClosed
+		}
+		d.pending = b
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *fakeDTE) Write(p []byte) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.sent.Write(p)
+}
+
+func (d *fakeDTE) String() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.sent.String()
+}
+
+func testSerial(flow int) (*serialPort, *fakeDTE, *testPins) {
+	pins := &testPins{rts: true, dtr: true, cts: true}
+	m := &Modem{log: log.New(ioutil.Discard, "", 0), pins: pins}
+	dte := &fakeDTE{typed: make(chan []byte, 10)}
+	s := newSerialPort(m, dte)
+	s.setFlowControl(flow)
+	return s, dte, pins
+}
+
+// Run getChars() over what the DTE types, until it's done
+func (s *serialPort) typed(d *fakeDTE, b ...string) string {
+	for _, t := range b {
+		d.typed <- []byte(t)
+	}
+	close(d.typed)
+	s.getChars()
+
+	p := make([]byte, __TX_BUFFER)
+	return string(p[:s.in.TryRead(p)])
+}
+
+func TestFlowControlInput(t *testing.T) {
+	tests := []struct {
+		name string
+		flow int
+		in   []string
+		want string
+		xoff bool // Did the DTE tell us to stop?
+	}{
+		{"none", FLOW_NONE, []string{"a\x13b"}, "a\x13b", false},
+		{"RTS/CTS", FLOW_RTSCTS, []string{"a\x13b"}, "a\x13b", false},
+		{"XOFF", FLOW_XONXOFF, []string{"ab\x13"}, "ab", true},
+		{"XOFF, XON", FLOW_XONXOFF, []string{"a\x13b\x11c"}, "abc",
+			false},
+		{"XON, XOFF", FLOW_XONXOFF, []string{"\x11a", "b\x13"}, "ab",
+			true},
+		{"only XOFF", FLOW_XONXOFF, []string{"\x13"}, "", true},
+	}
+	for _, tt := range tests {
+		s, dte, _ := testSerial(tt.flow)
+		if got := s.typed(dte, tt.in...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if s.xoff != tt.xoff || s.stopped() != tt.xoff {
+			t.Errorf("%s: xoff %t, stopped %t, want %t", tt.name,
+				s.xoff, s.stopped(), tt.xoff)
+		}
+	}
+}
+
+func TestFlowControlStopped(t *testing.T) {
+	tests := []struct {
+		flow      int
+		rts, xoff bool
+		want      bool
+	}{
+		{FLOW_NONE, false, true, false},
+		{FLOW_RTSCTS, true, true, false},
+		{FLOW_RTSCTS, false, false, true},
+		{FLOW_XONXOFF, false, false, false},
+		{FLOW_XONXOFF, true, true, true},
+	}
+	for _, tt := range tests {
+		s, _, pins := testSerial(tt.flow)
+		pins.set(tt.rts, true)
+		s.xoff = tt.xoff
+		if got := s.stopped(); got != tt.want {
+			t.Errorf("&K%d, RTS %t, XOFF %t: stopped %t", tt.flow,
+				tt.rts, tt.xoff, got)
+		}
+	}
+}
+
+// Past the high water mark the DTE's held off, below the low water
+// mark it's let go
+func TestFlowControlHold(t *testing.T) {
+	tests := []struct {
+		flow           int
+		held, released string // What the DTE's sent
+		cts            bool   // CTS while held
+	}{
+		{FLOW_NONE, "", "", true},
+		{FLOW_RTSCTS, "", "", false},
+		{FLOW_XONXOFF, "\x13", "\x13\x11", true},
+	}
+	for _, tt := range tests {
+		s, dte, pins := testSerial(tt.flow)
+		dte.typed <- bytes.Repeat([]byte{'x'}, __TX_HIGH)
+		close(dte.typed)
+		s.getChars()
+		if !s.held || dte.String() != tt.held || pins.ReadCTS() != tt.cts {
+			t.Errorf("&K%d: held %t, sent %q, CTS %t", tt.flow, s.held,
+				dte.String(), pins.ReadCTS())
+		}
+
+		s.in.TryRead(make([]byte, __TX_HIGH-__TX_LOW-1))
+		s.drained()
+		if !s.held {
+			t.Errorf("&K%d: let go above the low water mark", tt.flow)
+		}
+		s.in.TryRead(make([]byte, 1))
+		s.drained()
+		if s.held || dte.String() != tt.released || !pins.ReadCTS() {
+			t.Errorf("&K%d: held %t, sent %q, CTS %t once drained",
+				tt.flow, s.held, dte.String(), pins.ReadCTS())
+		}
+	}
+}
+
+// Switching flow control doesn't leave the DTE held off
+func TestFlowControlSwitch(t *testing.T) {
+	s, dte, pins := testSerial(FLOW_RTSCTS)
+	s.hold(true)
+	s.setFlowControl(FLOW_XONXOFF)
+	if s.held || !pins.ReadCTS() || dte.String() != "" {
+		t.Errorf("held %t, CTS %t, sent %q", s.held, pins.ReadCTS(),
+			dte.String())
+	}
+
+	s.hold(true)
+	s.setFlowControl(FLOW_NONE)
+	if s.held || dte.String() != "\x13\x11" || s.m.conf.flowControl !=
+		FLOW_NONE {
+		t.Errorf("held %t, sent %q, &K%d", s.held, dte.String(),
+			s.m.conf.flowControl)
+	}
+}
+
+// Write() waits while the DTE isn't ready, unless it's gone
+func TestFlowControlWrite(t *testing.T) {
+	s, dte, pins := testSerial(FLOW_RTSCTS)
+	pins.set(false, true)
+
+	done := make(chan bool)
+	go func() {
+		s.Write([]byte("hello"))
+		done <- true
+	}()
+	select {
+	case <-done:
+		t.Fatal("sent while RTS was low")
+	case <-time.After(10 * __FLOW_POLL):
+	}
+	pins.set(true, true)
+	<-done
+	if dte.String() != "hello" {
+		t.Errorf("sent %q", dte.String())
+	}
+
+	// A DTE that's dropped DTR isn't listening, so isn't waited for
+	pins.set(false, false)
+	start := time.Now()
+	s.Write([]byte("bye"))
+	if took := time.Since(start); took > __FLOW_TIMEOUT/2 {
+		t.Errorf("waited %s with DTR down", took)
+	}
+	if dte.String() != "hellobye" {
+		t.Errorf("sent %q", dte.String())
+	}
+}
+
+// A DTE that's gone for good isn't read again, and one that fails
+// isn't read in a tight loop
+func TestDTEReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		reads int
+		min   time.Duration
+	}{
+		{"closed", net.ErrClosed, 1, 0},
+		{"failed", errors.New("parity error"), 2, __DTE_RETRY},
+	}
+	for _, tt := range tests {
+		s, dte, _ := testSerial(FLOW_NONE)
+		dte.err = tt.err
+		if tt.reads > 1 {
+			close(dte.typed)
+		}
+
+		start := time.Now()
+		s.getChars() // Returns once the DTE's closed
+		if took := time.Since(start); dte.reads != tt.reads ||
+			took < tt.min {
+			t.Errorf("%s: %d reads in %s", tt.name, dte.reads, took)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package modem
+
+// Support for generic hardare (ie, not a Raspberry Pi)
+
+import (
+	"log"
+	"runtime"
+	"strings"
+)
+
+const (
+	RI_PIN = iota
+	CD_PIN
+	DSR_PIN
+	CTS_PIN
+	DTR_PIN
+	RTS_PIN
+
+	_PIN_LEN // This needs to be last in the const list
+)
+
+// Simulated pins and LEDs
+type simPins struct {
+	leds [_LED_LEN]bool
+	pins [_PIN_LEN]bool
+	log  *log.Logger
+}
+
+func NewSimulatedPins(log *log.Logger) Pins {
+	return &simPins{log: log}
+}
+
+func (s *simPins) Setup() {
+	s.log.Printf("Simulated Pins enabled on %s/%s\n",
+		runtime.GOOS, runtime.GOARCH)
+
+	s.Clear()
+
+	// The DTE is always ready
+	s.pins[DTR_PIN] = true
+	s.pins[RTS_PIN] = true
+}
+
+func (s *simPins) Clear() {
+	for i := range s.leds {
+		s.leds[i] = false
+	}
+	for i := range s.pins {
+		s.pins[i] = false
+	}
+}
+
+func (s *simPins) String() string {
+
+	pp := func(n string, p int) string {
+		var str string
+		if s.pins[p] {
+			str = strings.ToUpper(n)
+		} else {
+			str = strings.ToLower(n)
+		}
+		str += " "
+		return str
+	}
+	str := "PINs: ["
+	str += pp("CTS", CTS_PIN)
+	str += pp("RI ", RI_PIN)
+	str += pp("CD ", CD_PIN)
+	str += pp("DSR", DSR_PIN)
+	str += pp("RTS", RTS_PIN)
+	str += pp("DTR", DTR_PIN)
+	str += "]\n"
+
+	pl := func(n string, p int) string {
+		var str string
+		if s.leds[p] { // LED is on
+			str = strings.ToUpper(n)
+		} else {
+			str = strings.ToLower(n)
+		}
+
+		str += " "
+		return str
+	}
+	str += "LEDs: [ "
+	str += pl("HS", HS_LED)
+	str += pl("AA", AA_LED)
+	str += pl("RI", RI_LED)
+	str += pl("CD", CD_LED)
+	str += pl("OH", OH_LED)
+	str += pl("SD", SD_LED)
+	str += pl("RD", RD_LED)
+	str += pl("TR", TR_LED)
+	str += pl("CS", CS_LED)
+	str += pl("MR", MR_LED)
+	str += "]"
+	return str
+}
+
+// LED functions
+func (s *simPins) LED(led int, on bool) {
+	s.leds[led] = on
+}
+
+func (s *simPins) LEDTest(i int) {
+	// NOOP
+}
+
+// PINs
+
+// RI - Ring Indicator
+func (s *simPins) RaiseRI() {
+	s.pins[RI_PIN] = true
+}
+func (s *simPins) LowerRI() {
+	s.pins[RI_PIN] = false
+}
+func (s *simPins) ReadRI() bool {
+	return s.pins[RI_PIN]
+}
+
+// CD - Carrier Detect
+func (s *simPins) RaiseCD() {
+	s.leds[CD_LED] = true
+	s.pins[CD_PIN] = true
+}
+func (s *simPins) LowerCD() {
+	s.leds[CD_LED] = false
+	s.pins[CD_PIN] = false
+}
+func (s *simPins) ReadCD() bool {
+	return s.pins[CD_PIN]
+}
+
+// DSR - Data Set Ready
+func (s *simPins) RaiseDSR() {
+	s.leds[MR_LED] = true
+	s.pins[DSR_PIN] = true
+	s.log.Print("raiseDSR()")
+}
+func (s *simPins) LowerDSR() {
+	s.leds[MR_LED] = false
+	s.pins[DSR_PIN] = false
+	s.log.Print("lowerDSR()")
+}
+func (s *simPins) ReadDSR() bool {
+	return s.pins[DSR_PIN]
+}
+
+// CTS - Clear to Send
+func (s *simPins) RaiseCTS() {
+	s.leds[CS_LED] = true
+	s.pins[CTS_PIN] = true
+	s.log.Print("raiseCTS()")
+}
+func (s *simPins) LowerCTS() {
+	s.leds[CS_LED] = true
+	s.pins[CTS_PIN] = false
+	s.log.Print("lowerCTS()")
+}
+func (s *simPins) ReadCTS() bool {
+	return s.pins[CTS_PIN]
+}
+
+// DTR - Data Terminal Ready (input)
+func (s *simPins) ReadDTR() bool {
+	// Is the computer ready to send data?
+	return s.pins[DTR_PIN]
+}
+
+// RTS - Request to Send (input)
+func (s *simPins) ReadRTS() bool {
+	// Has the computer requested data be sent?
+	return s.pins[RTS_PIN]
+}
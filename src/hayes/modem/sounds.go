@@ -0,0 +1,181 @@
+package modem
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// The sounds a modem's speaker makes.  Tones are per the Precise Tone
+// Plan and the DTMF standard; the handshake is a short V.34 style
+// answer tone, V.8 exchange, line probe and training.
+
+// A sound is the sample (-1 to 1) t seconds in.  Sounds are asked for
+// their samples in order, so they can keep state.
+type sound func(t float64) float64
+
+type timedSound struct {
+	snd sound
+	d   time.Duration
+}
+
+func silence(t float64) float64 {
+	return 0
+}
+
+// Equal parts of each frequency
+func tones(freqs ...float64) sound {
+	return func(t float64) float64 {
+		v := 0.0
+		for _, f := range freqs {
+			v += math.Sin(2 * math.Pi * f * t)
+		}
+		return v / float64(len(freqs))
+	}
+}
+
+// Both at once
+func mix(a, b sound) sound {
+	return func(t float64) float64 {
+		return (a(t) + b(t)) / 2
+	}
+}
+
+// snd for on, then silence for off, over and over
+func cadence(snd sound, on, off time.Duration) sound {
+	period := (on + off).Seconds()
+	return func(t float64) float64 {
+		if math.Mod(t, period) < on.Seconds() {
+			return snd(t)
+		}
+		return 0
+	}
+}
+
+var (
+	dialTone = tones(350, 440)
+	ringback = cadence(tones(440, 480), 2*time.Second, 4*time.Second)
+	busyTone = cadence(tones(480, 620), 500*time.Millisecond,
+		500*time.Millisecond)
+
+	// V.25, so the other end knows it's a modem calling
+	callingTone = cadence(tones(1300), 500*time.Millisecond,
+		2*time.Second)
+)
+
+// The DTMF row and column frequencies for each key
+var dtmfKeys = map[rune][2]float64{
+	'1': {697, 1209}, '2': {697, 1336}, '3': {697, 1477}, 'A': {697, 1633},
+	'4': {770, 1209}, '5': {770, 1336}, '6': {770, 1477}, 'B': {770, 1633},
+	'7': {852, 1209}, '8': {852, 1336}, '9': {852, 1477}, 'C': {852, 1633},
+	'*': {941, 1209}, '0': {941, 1336}, '#': {941, 1477}, 'D': {941, 1633},
+}
+
+// Letters dial as they would on a phone's keypad, so host names make
+// a noise too
+var keypad = strings.NewReplacer(
+	"a", "2", "b", "2", "c", "2", "d", "3", "e", "3", "f", "3",
+	"g", "4", "h", "4", "i", "4", "j", "5", "k", "5", "l", "5",
+	"m", "6", "n", "6", "o", "6", "p", "7", "q", "7", "r", "7", "s", "7",
+	"t", "8", "u", "8", "v", "8", "w", "9", "x", "9", "y", "9", "z", "9")
+
+// Each key in number as a DTMF tone lasting d, with d between them.
+// Anything that isn't a key is skipped.
+func dtmf(number string, d time.Duration) []timedSound {
+	var s []timedSound
+	for _, k := range keypad.Replace(strings.ToLower(number)) {
+		f, ok := dtmfKeys[k]
+		if !ok {
+			continue
+		}
+		s = append(s, timedSound{tones(f[0], f[1]), d},
+			timedSound{silence, d})
+	}
+	return s
+}
+
+// Pulse dialing: each digit n is n clicks (10 for 0) at 10 a second,
+// with 700ms between digits
+func pulses(number string) []timedSound {
+	click := func(t float64) float64 { // Decays over a few ms
+		return math.Exp(-t*1500) * math.Sin(2*math.Pi*900*t)
+	}
+	var s []timedSound
+	for _, k := range number {
+		if k < '0' || k > '9' {
+			continue
+		}
+		n := int(k - '0')
+		if n == 0 {
+			n = 10
+		}
+		for i := 0; i < n; i++ {
+			s = append(s, timedSound{click, 60 * time.Millisecond},
+				timedSound{silence, 40 * time.Millisecond})
+		}
+		s = append(s, timedSound{silence, 700 * time.Millisecond})
+	}
+	return s
+}
+
+// Frequency shift keying: random bits at baud, mark and space
+// frequencies around f.  Keeps its phase between bits, so it warbles
+// rather than clicks.
+func fsk(f, shift, baud float64) sound {
+	var phase, last float64
+	bit := 0.0
+	return func(t float64) float64 {
+		if math.Floor(t*baud) != math.Floor(last*baud) {
+			bit = float64(rand.Intn(2))*2 - 1
+		}
+		phase += 2 * math.Pi * (f + bit*shift) * (t - last)
+		last = t
+		return math.Sin(phase)
+	}
+}
+
+// The answering modem's tone: 2100Hz, amplitude modulated at 15Hz, with
+// a phase reversal every 450ms (V.8 ANSam)
+func answerTone(t float64) float64 {
+	reversals := math.Floor(t / 0.45)
+	return math.Sin(2*math.Pi*2100*t+math.Pi*reversals) *
+		(1 + 0.2*math.Sin(2*math.Pi*15*t)) / 1.2
+}
+
+// V.34 line probing: a comb of tones every 150Hz from 150Hz to 3750Hz
+func lineProbe(t float64) float64 {
+	v := 0.0
+	for f := 150.0; f <= 3750; f += 150 {
+		v += math.Sin(2*math.Pi*f*t + f) // Spread the phases a bit
+	}
+	return v / 8
+}
+
+// Scrambled data sounds like hiss
+func hiss(t float64) float64 {
+	return rand.Float64()*2 - 1
+}
+
+// The screech, from the calling modem's point of view
+func handshake() []timedSound {
+	calling := fsk(1080, 100, 300) // V.8 goes over both V.21 channels
+	answering := fsk(1750, 100, 300)
+	v8 := func(t float64) float64 {
+		return (calling(t) + answering(t)) / 2
+	}
+	return []timedSound{
+		{answerTone, 2 * time.Second},
+		{v8, 600 * time.Millisecond},
+		{silence, 75 * time.Millisecond},
+		{cadence(lineProbe, 160*time.Millisecond, 40*time.Millisecond),
+			600 * time.Millisecond},
+		{func(t float64) float64 { return 0.6 * hiss(t) },
+			1500 * time.Millisecond},
+	}
+}
+
+// A carrier, under the data.  For ATM2.
+func carrier(t float64) float64 {
+	return 0.3 * hiss(t)
+}
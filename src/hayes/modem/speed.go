@@ -0,0 +1,141 @@
+package modem
+
+import (
+	"sync"
+	"time"
+)
+
+// S37 values and the line speeds they select.  1-13 are as per the
+// Hayes manual, 20-22 are extensions for speeds no phone line ever
+// carried.  0 means "as fast as possible", which is as fast as the
+// network goes.
+var lineSpeeds = map[byte]int{
+	1:  300,
+	2:  300,
+	3:  300,
+	5:  1200,
+	6:  2400,
+	7:  4800,
+	8:  7200,
+	9:  9600,
+	10: 12000,
+	11: 14400,
+	13: 19200,
+	20: 38400,
+	21: 57600,
+	22: 115200,
+}
+
+// What we report when running unthrottled
+const __MAX_SPEED = 38400
+
+// The fastest speed in lineSpeeds no faster than bps, or the slowest
+// if they're all faster
+func knownSpeed(bps int) int {
+	best := 0
+	for _, s := range lineSpeeds {
+		if s <= bps && s > best {
+			best = s
+		}
+	}
+	if best == 0 {
+		return lineSpeeds[1]
+	}
+	return best
+}
+
+// The speed to connect at per S37, 0 == unthrottled
+func (m *Modem) lineSpeed() int {
+	return lineSpeeds[m.registers.Read(REG_LINE_SPEED)]
+}
+
+// Run the data pumps at rate bps (0 == unthrottled) and report it in
+// the CONNECT result.  The pumps may already be running, so the pacers
+// are changed in place.
+func (m *Modem) setLineRate(rate int) {
+	speed := rate
+	if rate == 0 {
+		speed = __MAX_SPEED
+	}
+	m.rxPace.setSpeed(rate)
+	m.txPace.setSpeed(rate)
+	m.stateLock.Lock()
+	m.connectSpeed = speed
+	m.stateLock.Unlock()
+	m.log.Printf("Line speed %d bps (0 == unthrottled)", rate)
+}
+
+// No call, so no line speed
+func (m *Modem) clearLineRate() {
+	m.rxPace.setSpeed(0)
+	m.txPace.setSpeed(0)
+	m.stateLock.Lock()
+	m.connectSpeed = 0
+	m.stateLock.Unlock()
+}
+
+func (m *Modem) getConnectSpeed() int {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	return m.connectSpeed
+}
+
+// How far behind schedule we let a pacer get before we stop trying to
+// catch up.  Covers time.Sleep() overshooting at high speeds.
+const __PACE_SLACK = 100 * time.Millisecond
+
+// Paces a byte stream to a line speed.  Every byte is 10 bits on the
+// wire: a start bit, 8 data bits and a stop bit.  Each is used by one
+// pump, but its speed is set from elsewhere, hence the lock.
+type pacer struct {
+	lock  sync.Mutex
+	speed int // bps, 0 == unthrottled
+	next  time.Time
+}
+
+func (p *pacer) setSpeed(speed int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.speed = speed
+	p.next = time.Time{}
+}
+
+// How much to send in one go: 10ms worth at the line speed, and never
+// more than max.
+func (p *pacer) chunk(max int) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.speed == 0 {
+		return max
+	}
+	n := p.speed / 1000 // 10 bits a byte, 100 chunks a second
+	if n < 1 {
+		n = 1
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// Block until n more bytes would have made it down the line
+func (p *pacer) wait(n int) {
+	p.lock.Lock()
+	if p.speed == 0 {
+		p.lock.Unlock()
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(p.next) > __PACE_SLACK { // Idle line
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(n*10) * time.Second /
+		time.Duration(p.speed))
+	d := p.next.Sub(now)
+	p.lock.Unlock() // Don't hold up setSpeed() while we sleep
+
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,770 @@
+package modem
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"context"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How long an inbound caller has to log in, and then to start a shell
+const (
+	__SSH_LOGIN_TIMEOUT   = 30 * time.Second
+	__SSH_SESSION_TIMEOUT = 30 * time.Second
+)
+
+// Implements connection for in-bound ssh.  Each session channel is a
+// call of its own.
+type sshAcceptReadWriteCloser struct {
+	sent, recv uint64 // Atomic, see Connection
+	c          ssh.Channel
+	rx         *deadlineReader
+	server     *sshServerConn
+	remoteAddr net.Addr
+	log        *log.Logger
+	closeOnce  sync.Once
+
+	// What the caller told us about their terminal
+	lock       sync.Mutex
+	term       string
+	cols, rows int
+	env        map[string]string
+}
+
+func (m *sshAcceptReadWriteCloser) String() string {
+	var s, host string
+	ip, _, err := net.SplitHostPort(m.RemoteAddr().String())
+	if err != nil {
+		m.log.Printf("SplitHostPort(): %s", err)
+	}
+	names, err := net.LookupAddr(ip)
+	if err != nil {
+		host = "(nil)"
+		m.log.Printf("LookupAddr(): %s", err)
+	} else {
+		host = names[0]
+	}
+	sent, recv := m.Stats()
+
+	s = fmt.Sprintf("Inbound SSH from %s@%s (%s), sent %s, received %s",
+		m.server.User(), host, m.RemoteAddr(), bytefmt.ByteSize(sent),
+		bytefmt.ByteSize(recv))
+
+	term, cols, rows := m.terminal()
+	if term != "" {
+		s += fmt.Sprintf(", terminal %s %dx%d", term, cols, rows)
+	}
+	return s
+}
+
+func (m *sshAcceptReadWriteCloser) Read(p []byte) (int, error) {
+	i, err := m.rx.Read(p)
+	atomic.AddUint64(&m.recv, uint64(i))
+	return i, err
+}
+
+func (m *sshAcceptReadWriteCloser) Write(p []byte) (int, error) {
+	if m.rx.expired() {
+		return 0, timeoutError{}
+	}
+	i, err := m.c.Write(p)
+	atomic.AddUint64(&m.sent, uint64(i))
+	return i, err
+}
+
+// Hang up: tell the client the "shell" is done, and drop the
+// connection if this was its last call
+func (m *sshAcceptReadWriteCloser) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		m.c.SendRequest("exit-status", false,
+			ssh.Marshal(struct{ Status uint32 }{0}))
+		err = m.c.Close()
+		m.rx.Close()
+		m.server.callEnded()
+	})
+	return err
+}
+
+// The caller's terminal type and screen size
+func (m *sshAcceptReadWriteCloser) terminal() (string, int, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.term, m.cols, m.rows
+}
+
+func (m *sshAcceptReadWriteCloser) RemoteAddr() net.Addr {
+	return m.remoteAddr
+}
+
+func (m *sshAcceptReadWriteCloser) Direction() int {
+	return INBOUND
+}
+
+func (m *sshAcceptReadWriteCloser) Stats() (uint64, uint64) {
+	return atomic.LoadUint64(&m.sent), atomic.LoadUint64(&m.recv)
+}
+
+func (m *sshAcceptReadWriteCloser) SetDeadline(t time.Time) error {
+	m.rx.SetDeadline(t)
+	return nil
+}
+
+func acceptSSH(channel chan Connection, sshdPort uint, private_key string,
+	auth *sshAuth, screen *callScreen, busy busyFunc, log *log.Logger,
+	ok chan error) {
+
+	// In the latest version of crypto/ssh (after Go 1.3), the SSH
+	// server type has been removed in favour of an SSH connection
+	// type. A ssh.ServerConn is created by passing an existing
+	// net.Conn and a ssh.ServerConfig to ssh.NewServerConn, in
+	// effect, upgrading the net.Conn into an ssh.ServerConn
+
+	config := &ssh.ServerConfig{MaxAuthTries: 6}
+	auth.configure(config)
+
+	// You can generate a keypair with 'ssh-keygen -t rsa'
+	log.Printf("Loading SSH private key from %s", private_key)
+	privateBytes, err := ioutil.ReadFile(private_key)
+	if err != nil {
+		log.Printf("Fatal Error: failed to load private key (%s): %s\n",
+			private_key, err)
+		ok <- err
+		return
+	}
+
+	private, err := ssh.ParsePrivateKey(privateBytes)
+	if err != nil {
+		log.Print("Fatal Error: failed to parse private key: ", err)
+		ok <- err
+		return
+	}
+
+	config.AddHostKey(private)
+
+	// Once a ServerConfig has been configured, connections can be accepted.
+	address := "0.0.0.0:" + fmt.Sprintf("%d", sshdPort)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Print("Fatal Error: ", err)
+		ok <- err
+		return
+	}
+	log.Printf("Listening: ssh/%s", address)
+
+	// Accept all connections
+	ok <- nil
+	for {
+		tcpConn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Failed to accept incoming connection (%s)", err)
+			continue
+		}
+		if auth.blocked(tcpConn.RemoteAddr()) {
+			log.Printf("Ignoring SSH connection from %s",
+				tcpConn.RemoteAddr())
+			tcpConn.Close()
+			continue
+		}
+		go serviceSSH(tcpConn, config, channel, screen, busy, log)
+	}
+}
+
+// An inbound SSH connection, which may carry several sessions
+type sshServerConn struct {
+	*ssh.ServerConn
+	lock    sync.Mutex
+	calls   int  // Sessions handed to the modem and not hung up yet
+	started bool // Has any session been handed over?
+	log     *log.Logger
+}
+
+func (s *sshServerConn) callStarted() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls++
+	s.started = true
+}
+
+// Nothing left on the connection once the last call hangs up
+func (s *sshServerConn) callEnded() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls--
+	if s.calls == 0 {
+		s.log.Printf("Closing SSH connection from %s", s.RemoteAddr())
+		s.Close()
+	}
+}
+
+// Close the connection if it never got as far as a call
+func (s *sshServerConn) idle() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.started {
+		s.log.Printf("No session from %s, closing the connection",
+			s.RemoteAddr())
+		s.Close()
+	}
+}
+
+// Log the caller in and answer their sessions.
+// Must be a goroutine
+func serviceSSH(tcpConn net.Conn, config *ssh.ServerConfig,
+	channel chan Connection, screen *callScreen, busy busyFunc,
+	log *log.Logger) {
+
+	// Turn away callers we don't take before they get to try a
+	// password
+	if screen.reject(tcpConn.RemoteAddr()) {
+		tcpConn.Write([]byte("Busy...\n\r"))
+		tcpConn.Close()
+		return
+	}
+
+	// Before use, a handshake must be performed on the incoming
+	// net.Conn.  Don't let a caller who never finishes it hold on to
+	// the socket.
+	tcpConn.SetDeadline(time.Now().Add(__SSH_LOGIN_TIMEOUT))
+	sshConn, chans, reqs, err := ssh.NewServerConn(tcpConn, config)
+	if err != nil {
+		log.Printf("Failed to handshake (%s)", err)
+		return
+	}
+	tcpConn.SetDeadline(time.Time{})
+	go ssh.DiscardRequests(reqs)
+
+	log.Printf("New SSH connection from %s@%s (%s)\n",
+		sshConn.User(), sshConn.RemoteAddr(),
+		sshConn.ClientVersion())
+
+	s := &sshServerConn{ServerConn: sshConn, log: log}
+	idle := time.AfterFunc(__SSH_SESSION_TIMEOUT, s.idle)
+	defer idle.Stop()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType,
+				"unknown channel type")
+			continue
+		}
+		go serviceSSHSession(newChannel, s, channel, busy, log)
+	}
+	log.Printf("SSH connection from %s closed", sshConn.RemoteAddr())
+}
+
+// Answer a session's requests, and when it asks for a shell, ring the
+// modem.
+// Must be a goroutine
+func serviceSSHSession(newChannel ssh.NewChannel, s *sshServerConn,
+	channel chan Connection, busy busyFunc, log *log.Logger) {
+
+	ch, reqs, err := newChannel.Accept()
+	if err != nil {
+		log.Print("Can't accept SSH session: ", err)
+		return
+	}
+	c := &sshAcceptReadWriteCloser{c: ch,
+		rx: newDeadlineReader(ch), server: s,
+		remoteAddr: s.RemoteAddr(), log: log,
+		env: make(map[string]string)}
+
+	shell := false
+	for req := range reqs {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			var p struct {
+				Term                      string
+				Cols, Rows, Width, Height uint32
+				Modes                     string
+			}
+			if ssh.Unmarshal(req.Payload, &p) == nil {
+				c.lock.Lock()
+				c.term, c.cols, c.rows = p.Term, int(p.Cols),
+					int(p.Rows)
+				c.lock.Unlock()
+				log.Printf("SSH pty: %s %dx%d", p.Term, p.Cols,
+					p.Rows)
+				ok = true
+			}
+
+		case "window-change":
+			var w struct{ Cols, Rows, Width, Height uint32 }
+			if ssh.Unmarshal(req.Payload, &w) == nil {
+				c.lock.Lock()
+				c.cols, c.rows = int(w.Cols), int(w.Rows)
+				c.lock.Unlock()
+				ok = true
+			}
+
+		case "env":
+			var e struct{ Name, Value string }
+			if ssh.Unmarshal(req.Payload, &e) == nil {
+				c.lock.Lock()
+				c.env[e.Name] = e.Value
+				c.lock.Unlock()
+				log.Printf("SSH env: %s=%s", e.Name, e.Value)
+				ok = true
+			}
+
+		case "shell":
+			ok = !shell
+		}
+
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+
+		// Only once the client knows it has a shell
+		if req.Type == "shell" && ok {
+			shell = true
+			s.callStarted()
+			go offerCall(channel, c, busy, log)
+		}
+	}
+
+	if !shell { // Gave up before asking for a shell
+		ch.Close()
+	}
+}
+
+// The pty we ask SSH hosts for, by default
+const (
+	__SSH_TERM = "xterm"
+	__SSH_COLS = 80
+	__SSH_ROWS = 24
+)
+
+// Implements connection, used to convert SSH ssh.Session for outbound SSH
+type sshDialReadWriteCloser struct {
+	sent, recv uint64 // Atomic, see Connection
+	in         *deadlineReader
+	out        io.WriteCloser
+	client     *ssh.Client
+	session    *ssh.Session
+	remoteAddr net.Addr
+	log        *log.Logger
+}
+
+func (m *sshDialReadWriteCloser) String() string {
+	var s, host string
+	ip, _, err := net.SplitHostPort(m.RemoteAddr().String())
+	if err != nil {
+		m.log.Printf("SplitHostPort(): %s", err)
+	}
+	names, err := net.LookupAddr(ip)
+	if err != nil {
+		host = "(nil)"
+		m.log.Printf("LookupAddr(): %s", err)
+	} else {
+		host = names[0]
+	}
+	sent, recv := m.Stats()
+
+	s = fmt.Sprintf("Outbound SSH to %s (%s), sent %s, received %s",
+		host, m.RemoteAddr(), bytefmt.ByteSize(sent),
+		bytefmt.ByteSize(recv))
+
+	return s 
+}
+
+func (m *sshDialReadWriteCloser) Read(p []byte) (int, error) {
+	i, err := m.in.Read(p)
+	atomic.AddUint64(&m.recv, uint64(i))
+	return i, err
+}
+
+func (m *sshDialReadWriteCloser) Write(p []byte) (int, error) {
+	if m.in.expired() {
+		return 0, timeoutError{}
+	}
+	i, err := m.out.Write(p)
+	atomic.AddUint64(&m.sent, uint64(i))
+	return i, err
+}
+
+func (m *sshDialReadWriteCloser) Close() error {
+	err := m.out.Close()
+	if m.session != nil { // Subsystems don't have one
+		m.session.Close()
+	}
+	m.client.Close()
+	m.in.Close()
+	return err
+}
+
+func (m *sshDialReadWriteCloser) Direction() int {
+	return OUTBOUND
+}
+
+func (m *sshDialReadWriteCloser) RemoteAddr() net.Addr {
+	return m.remoteAddr
+}
+
+func (m *sshDialReadWriteCloser) Stats() (uint64, uint64) {
+	return atomic.LoadUint64(&m.sent), atomic.LoadUint64(&m.recv)
+}
+
+func (m *sshDialReadWriteCloser) SetDeadline(t time.Time) error {
+	m.in.SetDeadline(t)
+	return nil
+}
+
+func (m *sshDialReadWriteCloser) resize(cols, rows int) {
+	if m.session == nil { // No pty to resize
+		return
+	}
+	if cols == 0 {
+		cols = __SSH_COLS
+	}
+	if rows == 0 {
+		rows = __SSH_ROWS
+	}
+	m.log.Printf("SSH window change to %dx%d", cols, rows)
+	if err := m.session.WindowChange(rows, cols); err != nil {
+		m.log.Print("WindowChange(): ", err)
+	}
+}
+
+// How we prove who we are to the remote: keys from the agent and the
+// key file, then the password, which also answers keyboard-interactive
+// prompts.  Call done() once connected.
+func sshClientAuth(e PhonebookEntry,
+	log *log.Logger) (auth []ssh.AuthMethod, done func(), err error) {
+
+	var signers []ssh.Signer
+	done = func() {}
+
+	if e.Agent != "" {
+		sock := os.ExpandEnv(e.Agent)
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			log.Printf("Can't reach ssh-agent at '%s': %s", sock, err)
+			return nil, done, err
+		}
+		done = func() { conn.Close() }
+		s, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			log.Printf("ssh-agent: %s", err)
+			return nil, done, err
+		}
+		log.Printf("%d key(s) from ssh-agent at %s", len(s), sock)
+		signers = append(signers, s...)
+	}
+
+	if e.KeyFile != "" {
+		filename := os.ExpandEnv(e.KeyFile)
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			log.Printf("Can't read SSH key: %s", err)
+			return nil, done, err
+		}
+		// An encrypted key's passphrase is the password
+		var s ssh.Signer
+		if e.Password != "" {
+			s, err = ssh.ParsePrivateKeyWithPassphrase(b,
+				[]byte(e.Password))
+		} else {
+			s, err = ssh.ParsePrivateKey(b)
+		}
+		if err != nil {
+			log.Printf("Can't load SSH key %s: %s", filename, err)
+			return nil, done, err
+		}
+		log.Printf("Using %s key from %s", s.PublicKey().Type(),
+			filename)
+		signers = append(signers, s)
+	}
+
+	// The client only tries each method once, so every key goes in
+	// together
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if e.Password != "" {
+		pw := e.Password
+		auth = append(auth, ssh.Password(pw),
+			ssh.KeyboardInteractive(func(user, instruction string,
+				questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = pw
+				}
+				return answers, nil
+			}))
+	}
+	return auth, done, nil
+}
+
+// ssh.Dial(), giving up when ctx is done
+func sshDialContext(ctx context.Context, remote string,
+	config *ssh.ClientConfig) (*ssh.Client, error) {
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", remote)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake and login know nothing of ctx, so stop them by
+	// closing the connection under them
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	c, chans, reqs, err := ssh.NewClientConn(conn, remote, config)
+	close(done)
+	<-stopped
+
+	if ctx.Err() != nil {
+		if err == nil {
+			c.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func dialSSH(ctx context.Context, e PhonebookEntry, knownHosts string,
+	log *log.Logger) (*sshDialReadWriteCloser, error) {
+	remote, username := e.Host, e.Username
+
+	if _, _, err := net.SplitHostPort(remote); err != nil {
+		remote += ":22"
+	}
+
+	log.Printf("Connecting to %s [user '%s']", remote, username)
+
+	changed := false
+	auth, done, err := sshClientAuth(e, log)
+	defer done()
+	if err != nil {
+		return &sshDialReadWriteCloser{}, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: trustOnFirstUse(knownHosts, &changed, log),
+	}
+
+	client, err := sshDialContext(ctx, remote, config)
+	if err != nil {
+		log.Print("Fatal Error: ssh.Dial(): ", err)
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			log.Print("ssh.Dial: Timed out")
+		}
+		if changed {
+			return &sshDialReadWriteCloser{}, errHostKeyChanged
+		}
+		return &sshDialReadWriteCloser{},
+			fmt.Errorf("ssh.Dial() failed: %w", err)
+	}
+
+	// Closing the client is how a request that's hung is given up on
+	// when ctx is done, and how a call that fails is cleaned up
+	stop := closeWhenDone(ctx, client)
+	var c *sshDialReadWriteCloser
+	if e.Subsystem != "" {
+		c, err = startSubsystem(client, e.Subsystem, log)
+	} else {
+		c, err = startShell(client, e, log)
+	}
+	stop()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		client.Close()
+		return &sshDialReadWriteCloser{}, err
+	}
+
+	log.Printf("Connected to remote host '%s', SSH Server version %s",
+		client.Conn.RemoteAddr(), client.Conn.ServerVersion())
+	return c, nil
+}
+
+// Close c if ctx is done before stop is called.  Once stop returns, c
+// is left alone.
+func closeWhenDone(ctx context.Context, c io.Closer) (stop func()) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Log in to a shell, or run e's command, on a pty
+func startShell(client *ssh.Client, e PhonebookEntry,
+	log *log.Logger) (*sshDialReadWriteCloser, error) {
+
+	// Create a session
+	session, err := client.NewSession()
+	if err != nil {
+		log.Printf("unable to create session: %s", err)
+		return nil, fmt.Errorf("unable to create session: %s", err)
+	}
+
+	if err := requestPty(session, e, log); err != nil {
+		return nil, err
+	}
+
+	// Plumb in the remote end
+	send, err := session.StdinPipe()
+	if err != nil {
+		log.Print("StdinPipe(): ", err)
+		return nil, fmt.Errorf("session.StdinPipe(): %s", err)
+	}
+	recv, err := session.StdoutPipe()
+	if err != nil {
+		log.Print("StdoutPipe(): ", err)
+		return nil, fmt.Errorf("session.StdinOut(): %s", err)
+	}
+
+	if e.Command != "" {
+		log.Printf("Running '%s'", e.Command)
+		err = session.Start(e.Command)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		log.Print("Can't start remote session: ", err)
+		return nil, fmt.Errorf("remote session failed: %s", err)
+	}
+	go sshExitStatus(session, log)
+
+	return &sshDialReadWriteCloser{in: newDeadlineReader(recv), out: send,
+		client: client, session: session,
+		remoteAddr: client.Conn.RemoteAddr(), log: log}, nil
+}
+
+// Ask for a pty to suit the terminal described in e
+func requestPty(session *ssh.Session, e PhonebookEntry,
+	log *log.Logger) error {
+
+	// Set up terminal modes
+	speed := uint32(e.Speed)
+	if speed == 0 {
+		speed = __MAX_SPEED
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,     // disable echoing
+		ssh.TTY_OP_ISPEED: speed, // line speed, both ways
+		ssh.TTY_OP_OSPEED: speed,
+	}
+	// Request pseudo terminal
+	term, cols, rows := __SSH_TERM, __SSH_COLS, __SSH_ROWS
+	if e.TermType != "" {
+		term = e.TermType
+	}
+	if e.Cols > 0 {
+		cols = e.Cols
+	}
+	if e.Rows > 0 {
+		rows = e.Rows
+	}
+	log.Printf("Requesting %s pty, %dx%d at %d bps", term, cols, rows,
+		speed)
+	if err := session.RequestPty(term, rows, cols, modes); err != nil {
+		log.Print("request for pseudo terminal failed: ", err)
+		return fmt.Errorf("request for pty failed: %s", err)
+	}
+	return nil
+}
+
+// Subsystems are for programs, not people, so there's no pty.
+// ssh.Session won't say how a subsystem exited, so this drives the
+// session channel itself.
+func startSubsystem(client *ssh.Client, name string,
+	log *log.Logger) (*sshDialReadWriteCloser, error) {
+
+	log.Printf("Starting subsystem '%s'", name)
+	ch, reqs, err := client.OpenChannel("session", nil)
+	if err != nil {
+		log.Printf("unable to create session: %s", err)
+		return nil, fmt.Errorf("unable to create session: %s", err)
+	}
+	go sshChannelStatus(reqs, log)
+
+	ok, err := ch.SendRequest("subsystem", true,
+		ssh.Marshal(struct{ Name string }{name}))
+	if err == nil && !ok {
+		err = fmt.Errorf("subsystem '%s' refused", name)
+	}
+	if err != nil {
+		log.Print("Can't start remote session: ", err)
+		return nil, fmt.Errorf("remote session failed: %s", err)
+	}
+
+	return &sshDialReadWriteCloser{in: newDeadlineReader(ch), out: ch,
+		client: client, remoteAddr: client.Conn.RemoteAddr(),
+		log: log}, nil
+}
+
+// Log the exit status from a raw session channel's requests.
+// Must be a goroutine
+func sshChannelStatus(reqs <-chan *ssh.Request, log *log.Logger) {
+	for req := range reqs {
+		switch req.Type {
+		case "exit-status":
+			var status struct{ Status uint32 }
+			if err := ssh.Unmarshal(req.Payload, &status); err == nil {
+				log.Printf("Remote session exited with status %d",
+					status.Status)
+			}
+		case "exit-signal":
+			var signal struct{ Signal string }
+			if err := ssh.Unmarshal(req.Payload, &signal); err == nil {
+				log.Printf("Remote session killed by SIG%s",
+					signal.Signal)
+			}
+		}
+		if req.WantReply {
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// Log how the remote shell or command ended.  The modem sees the
+// session close and hangs up with NO CARRIER.
+// Must be a goroutine
+func sshExitStatus(session *ssh.Session, log *log.Logger) {
+	err := session.Wait()
+	switch e := err.(type) {
+	case nil:
+		log.Print("Remote session exited with status 0")
+	case *ssh.ExitError:
+		log.Printf("Remote session exited with status %d (%s)",
+			e.ExitStatus(), e)
+	case *ssh.ExitMissingError:
+		log.Print("Remote session ended without an exit status")
+	default:
+		log.Printf("Remote session ended: %s", err)
+	}
+}
@@ -0,0 +1,143 @@
+package modem
+
+import (
+	"context"
+	"crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// How the fake SSH server treats a session
+const (
+	sshRefuse = iota // Won't open one
+	sshNoPty         // Turns the pty down
+	sshHang          // Never answers the pty request
+	sshShell         // Gives us a shell
+)
+
+// An SSH server that lets anyone in, and says on closed when a client
+// has gone
+func fakeSSHServer(t *testing.T, mode int) (string, chan bool) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	closed := make(chan bool, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				c, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				go fakeSSHSessions(chans, mode)
+				c.Wait()
+				closed <- true
+			}()
+		}
+	}()
+	return l.Addr().String(), closed
+}
+
+func fakeSSHSessions(chans <-chan ssh.NewChannel, mode int) {
+	for nc := range chans {
+		if mode == sshRefuse {
+			nc.Reject(ssh.Prohibited, "no sessions here")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range reqs {
+				switch {
+				case mode == sshHang:
+				case mode == sshNoPty && req.Type == "pty-req":
+					req.Reply(false, nil)
+				default:
+					req.Reply(true, nil)
+				}
+			}
+		}()
+	}
+}
+
+// However far a call gets, the connection's closed if it fails
+func TestDialSSH(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    int
+		timeout time.Duration // S7, in effect
+		cancel  time.Duration // When the call's abandoned, if it is
+		ok      bool
+	}{
+		{"session refused", sshRefuse, time.Minute, 0, false},
+		{"pty refused", sshNoPty, time.Minute, 0, false},
+		{"pty hangs, S7 runs out", sshHang, time.Second, 0, false},
+		{"pty hangs, abandoned", sshHang, time.Minute,
+			500 * time.Millisecond, false},
+		{"shell", sshShell, time.Minute, 0, true},
+	}
+	for _, tt := range tests {
+		addr, closed := fakeSSHServer(t, tt.mode)
+		knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			tt.timeout)
+		if tt.cancel > 0 {
+			time.AfterFunc(tt.cancel, cancel)
+		}
+		start := time.Now()
+		c, err := dialSSH(ctx, PhonebookEntry{Host: addr,
+			Username: "user"}, knownHosts,
+			log.New(ioutil.Discard, "", 0))
+		took := time.Since(start)
+		cancel()
+
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want ok %t", tt.name, err, tt.ok)
+			continue
+		}
+		if took > 5*time.Second {
+			t.Errorf("%s: took %s", tt.name, took)
+		}
+		if err == nil {
+			select {
+			case <-closed:
+				t.Errorf("%s: closed while connected", tt.name)
+			default:
+			}
+			c.Close()
+		}
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: connection left open", tt.name)
+		}
+	}
+}
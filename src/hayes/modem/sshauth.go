@@ -0,0 +1,248 @@
+package modem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Failed SSH login attempts allowed from one address before it's shut
+// out, and for how long.  Clients offer each key they have in turn, so
+// this can't be too tight.
+const (
+	__SSH_MAX_FAILURES = 10
+	__SSH_FAIL_WINDOW  = time.Minute
+)
+
+// One entry in the SSH users file
+type sshUser struct {
+	Password string   `json:"Password,omitempty"` // bcrypt hash
+	Keys     []string `json:"Keys,omitempty"`     // authorized_keys lines
+}
+
+// Failed logins from one address
+type sshFailures struct {
+	count int
+	since time.Time
+}
+
+// Who may call in over SSH.  Shared by every SSH listener in a bank,
+// so failures on one port count against the others.
+type sshAuth struct {
+	users    map[string]sshUser
+	userKeys map[string]map[string]bool // Per-user keys, by user
+	keys     map[string]bool            // authorized_keys, anyone may use
+	open     bool                       // Nothing configured, and told to let anyone in
+	lock     sync.Mutex
+	failures map[string]*sshFailures // By IP address
+	log      *log.Logger
+}
+
+// Load the users file and the authorized_keys file.  Either may be
+// missing, but if both are nobody can log in, unless open says anyone
+// may.
+func newSSHAuth(usersFile, keysFile string, open bool,
+	log *log.Logger) (*sshAuth, error) {
+
+	a := &sshAuth{userKeys: make(map[string]map[string]bool),
+		keys:     make(map[string]bool),
+		failures: make(map[string]*sshFailures),
+		log:      log}
+
+	haveUsers, err := a.loadUsers(usersFile)
+	if err != nil {
+		return nil, err
+	}
+	haveKeys, err := a.loadKeys(keysFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case haveUsers || haveKeys:
+	case open:
+		log.Print("WARNING: no SSH users or authorized_keys, " +
+			"anyone can call in over SSH")
+		a.open = true
+	default:
+		return nil, fmt.Errorf("No SSH users (%s) or authorized keys "+
+			"(%s), nobody can call in over SSH", usersFile, keysFile)
+	}
+	return a, nil
+}
+
+func (a *sshAuth) loadUsers(filename string) (bool, error) {
+	if filename == "" {
+		return false, nil
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Can't read SSH users file %s: %s",
+			filename, err)
+	}
+	if err = json.Unmarshal(b, &a.users); err != nil {
+		return false, fmt.Errorf("Can't parse SSH users file %s: %s",
+			filename, err)
+	}
+
+	for user, u := range a.users {
+		keys := make(map[string]bool)
+		for _, line := range u.Keys {
+			k, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err != nil {
+				return false, fmt.Errorf("Bad key for SSH user %s: %s",
+					user, err)
+			}
+			keys[string(k.Marshal())] = true
+		}
+		a.userKeys[user] = keys
+	}
+	a.log.Printf("Loaded %d SSH users from %s", len(a.users), filename)
+	return true, nil
+}
+
+func (a *sshAuth) loadKeys(filename string) (bool, error) {
+	if filename == "" {
+		return false, nil
+	}
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Can't read authorized keys %s: %s",
+			filename, err)
+	}
+
+	for len(bytes.TrimSpace(b)) > 0 {
+		k, _, _, rest, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			return false, fmt.Errorf("Can't parse authorized keys %s: %s",
+				filename, err)
+		}
+		a.keys[string(k.Marshal())] = true
+		b = rest
+	}
+	a.log.Printf("Loaded %d authorized keys from %s", len(a.keys),
+		filename)
+	return true, nil
+}
+
+// With a users file, only the users in it can log in
+func (a *sshAuth) knownUser(user string) bool {
+	if a.users == nil {
+		return true
+	}
+	_, ok := a.users[user]
+	return ok
+}
+
+func (a *sshAuth) password(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+	if a.blocked(c.RemoteAddr()) {
+		return nil, fmt.Errorf("too many failures")
+	}
+	u, ok := a.users[c.User()]
+	if !ok || u.Password == "" {
+		return nil, fmt.Errorf("no password for %s", c.User())
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password),
+		pass); err != nil {
+		return nil, fmt.Errorf("wrong password for %s", c.User())
+	}
+	return nil, nil
+}
+
+func (a *sshAuth) publicKey(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	if a.blocked(c.RemoteAddr()) {
+		return nil, fmt.Errorf("too many failures")
+	}
+	k := string(key.Marshal())
+	if a.userKeys[c.User()][k] {
+		return nil, nil
+	}
+	if a.keys[k] && a.knownUser(c.User()) {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("key %s not authorized for %s",
+		ssh.FingerprintSHA256(key), c.User())
+}
+
+// Every attempt to log in, good or bad
+func (a *sshAuth) logAttempt(c ssh.ConnMetadata, method string, err error) {
+	switch {
+	case method == "none": // Clients always try this first
+	case err != nil:
+		a.log.Printf("SSH login failed from %s: %s: %s",
+			c.RemoteAddr(), method, err)
+		a.failed(c.RemoteAddr())
+	default:
+		a.log.Printf("SSH login by %s from %s (%s)",
+			c.User(), c.RemoteAddr(), method)
+	}
+}
+
+// Fill in how config checks who's calling
+func (a *sshAuth) configure(config *ssh.ServerConfig) {
+	if a.open {
+		config.NoClientAuth = true
+		return
+	}
+	if a.users != nil {
+		config.PasswordCallback = a.password
+	}
+	config.PublicKeyCallback = a.publicKey
+	config.AuthLogCallback = a.logAttempt
+}
+
+func addrIP(addr net.Addr) string {
+	ip, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return ip
+}
+
+// Has addr failed too often lately?
+func (a *sshAuth) blocked(addr net.Addr) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	f, ok := a.failures[addrIP(addr)]
+	if !ok {
+		return false
+	}
+	if time.Since(f.since) > __SSH_FAIL_WINDOW {
+		delete(a.failures, addrIP(addr))
+		return false
+	}
+	return f.count >= __SSH_MAX_FAILURES
+}
+
+// Count a failed login attempt from addr
+func (a *sshAuth) failed(addr net.Addr) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	ip := addrIP(addr)
+	f, ok := a.failures[ip]
+	if !ok || time.Since(f.since) > __SSH_FAIL_WINDOW {
+		f = &sshFailures{since: time.Now()}
+		a.failures[ip] = f
+	}
+	f.count++
+	if f.count == __SSH_MAX_FAILURES {
+		a.log.Printf("Too many failed SSH logins from %s, "+
+			"ignoring it for %s", ip, __SSH_FAIL_WINDOW)
+	}
+}
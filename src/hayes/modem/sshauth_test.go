@@ -0,0 +1,166 @@
+package modem
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// Who's logging in
+type fakeLogin struct {
+	user string
+}
+
+func (c fakeLogin) User() string          { return c.user }
+func (c fakeLogin) SessionID() []byte     { return nil }
+func (c fakeLogin) ClientVersion() []byte { return nil }
+func (c fakeLogin) ServerVersion() []byte { return nil }
+func (c fakeLogin) LocalAddr() net.Addr   { return c.RemoteAddr() }
+func (c fakeLogin) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234}
+}
+
+func testKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// Nothing configured means nobody gets in, unless we're told otherwise
+func TestSSHAuthFailsClosed(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "sshusers.json")
+	keys := filepath.Join(dir, "authorized_keys")
+	missing := filepath.Join(dir, "missing")
+	ioutil.WriteFile(users, []byte(`{"alice": {}}`), 0600)
+	ioutil.WriteFile(keys, ssh.MarshalAuthorizedKey(testKey(t)), 0600)
+
+	tests := []struct {
+		name        string
+		users, keys string
+		open        bool
+		wantErr     bool
+		wantOpen    bool
+	}{
+		{"nothing", missing, missing, false, true, false},
+		{"no file names", "", "", false, true, false},
+		{"nothing, but open", missing, missing, true, false, true},
+		{"users", users, missing, false, false, false},
+		{"keys", missing, keys, false, false, false},
+		{"keys and open", missing, keys, true, false, false},
+	}
+	for _, tt := range tests {
+		a, err := newSSHAuth(tt.users, tt.keys, tt.open,
+			log.New(ioutil.Discard, "", 0))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		var config ssh.ServerConfig
+		a.configure(&config)
+		if a.open != tt.wantOpen || config.NoClientAuth != tt.wantOpen {
+			t.Errorf("%s: open %t, NoClientAuth %t, want %t", tt.name,
+				a.open, config.NoClientAuth, tt.wantOpen)
+		}
+	}
+}
+
+func TestSSHAuthLogin(t *testing.T) {
+	aliceKey, anyKey, strangerKey := testKey(t), testKey(t), testKey(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"),
+		bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(map[string]sshUser{
+		"alice": {Password: string(hash),
+			Keys: []string{string(ssh.MarshalAuthorizedKey(aliceKey))}},
+		"bob": {},
+	})
+
+	dir := t.TempDir()
+	users := filepath.Join(dir, "sshusers.json")
+	keys := filepath.Join(dir, "authorized_keys")
+	ioutil.WriteFile(users, b, 0600)
+	ioutil.WriteFile(keys, ssh.MarshalAuthorizedKey(anyKey), 0600)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string        // Log in with this, or
+		key      ssh.PublicKey // this
+		ok       bool
+	}{
+		{"right password", "alice", "secret", nil, true},
+		{"wrong password", "alice", "guess", nil, false},
+		{"no password set", "bob", "", nil, false},
+		{"unknown user", "carol", "secret", nil, false},
+		{"user's key", "alice", "", aliceKey, true},
+		{"someone else's key", "bob", "", aliceKey, false},
+		{"authorized key", "bob", "", anyKey, true},
+		{"authorized key, unknown user", "carol", "", anyKey, false},
+		{"unknown key", "alice", "", strangerKey, false},
+	}
+	for _, tt := range tests {
+		a, err := newSSHAuth(users, keys, false,
+			log.New(ioutil.Discard, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var config ssh.ServerConfig
+		a.configure(&config)
+
+		login := fakeLogin{tt.user}
+		if tt.key != nil {
+			_, err = config.PublicKeyCallback(login, tt.key)
+		} else {
+			_, err = config.PasswordCallback(login, []byte(tt.password))
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v, want ok %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// Enough failures and even the right password doesn't work
+func TestSSHAuthBlocksGuessing(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"),
+		bcrypt.MinCost)
+	b, _ := json.Marshal(map[string]sshUser{
+		"alice": {Password: string(hash)}})
+	users := filepath.Join(t.TempDir(), "sshusers.json")
+	ioutil.WriteFile(users, b, 0600)
+
+	a, err := newSSHAuth(users, "", false, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config ssh.ServerConfig
+	a.configure(&config)
+
+	login := fakeLogin{"alice"}
+	for i := 0; i < __SSH_MAX_FAILURES; i++ {
+		_, err := config.PasswordCallback(login, []byte("guess"))
+		config.AuthLogCallback(login, "password", err)
+	}
+	if _, err := config.PasswordCallback(login,
+		[]byte("secret")); err == nil {
+		t.Error("logged in after too many failures")
+	}
+}
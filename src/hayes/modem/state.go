@@ -0,0 +1,228 @@
+package modem
+
+// The phone line, as a state machine.  Every change of state goes
+// through setState() (or transition()), under m.stateLock, and only the
+// changes in __TRANSITIONS are allowed, so two goroutines can't both,
+// say, answer a call or hang one up.
+type lineState int
+
+const (
+	IDLE           lineState = iota // On hook, no call
+	OFF_HOOK                        // Off hook with no call (ATH1)
+	DIALING                         // Placing a call
+	RINGING                         // A call is coming in, still on hook
+	CONNECTED_DATA                  // In a call, passing data
+	CONNECTED_CMD                   // In a call, in command mode
+	HANGING_UP                      // Dropping the call
+)
+
+var stateNames = []string{"IDLE", "OFF HOOK", "DIALING", "RINGING",
+	"CONNECTED (DATA)", "CONNECTED (COMMAND)", "HANGING UP"}
+
+func (s lineState) String() string {
+	return stateNames[s]
+}
+
+// Where each state can go next
+var __TRANSITIONS = map[lineState][]lineState{
+	IDLE:           {OFF_HOOK, DIALING, RINGING},
+	OFF_HOOK:       {DIALING, HANGING_UP},
+	DIALING:        {CONNECTED_DATA, CONNECTED_CMD, HANGING_UP},
+	RINGING:        {CONNECTED_DATA, IDLE, HANGING_UP}, // Answered, or not
+	CONNECTED_DATA: {CONNECTED_CMD, HANGING_UP},
+	CONNECTED_CMD:  {CONNECTED_DATA, HANGING_UP},
+	HANGING_UP:     {IDLE},
+}
+
+// Called on every change of state, in the order added.  They run after
+// the state's unlocked, one change at a time and in order, but maybe
+// not in the goroutine that made the change.
+type stateHook func(from, to lineState)
+
+type stateChange struct {
+	from, to lineState
+}
+
+func (m *Modem) onStateChange(h stateHook) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	m.stateHooks = append(m.stateHooks, h)
+}
+
+// The hooks every modem has
+func (m *Modem) defaultStateHooks() {
+	m.onStateChange(func(from, to lineState) {
+		m.log.Printf("Line state: %s -> %s", from, to)
+	})
+	m.onStateChange(func(from, to lineState) {
+		m.pins.LED(OH_LED, offHook(to))
+	})
+}
+
+// Move to state to, if it can be reached from where we are.  Returns
+// the state we were in, and whether we moved.  Must hold m.stateLock,
+// and let go of it with unlockState(), so the hooks get called.
+func (m *Modem) changeState(to lineState) (lineState, bool) {
+	from := m.state
+	if !canChange(from, to) {
+		return from, false
+	}
+	m.state = to
+	m.stateChanges = append(m.stateChanges, stateChange{from, to})
+	return from, true
+}
+
+func canChange(from, to lineState) bool {
+	for _, s := range __TRANSITIONS[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Unlock m.stateLock, then call the hooks for the changes made under
+// it.  If someone else is calling them already, they'll get ours too.
+func (m *Modem) unlockState() {
+	if m.hooksRunning || len(m.stateChanges) == 0 {
+		m.stateLock.Unlock()
+		return
+	}
+	m.hooksRunning = true
+	for len(m.stateChanges) > 0 {
+		changes, hooks := m.stateChanges, m.stateHooks
+		m.stateChanges = nil
+		m.stateLock.Unlock()
+		for _, c := range changes {
+			for _, h := range hooks {
+				h(c.from, c.to)
+			}
+		}
+		m.stateLock.Lock()
+	}
+	m.hooksRunning = false
+	m.stateLock.Unlock()
+}
+
+func (m *Modem) setState(to lineState) (lineState, bool) {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	return m.changeState(to)
+}
+
+// Move from from to to, but only if we're in from
+func (m *Modem) transition(from, to lineState) bool {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	if m.state != from {
+		return false
+	}
+	_, ok := m.changeState(to)
+	return ok
+}
+
+func (m *Modem) getState() lineState {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	return m.state
+}
+
+func offHook(s lineState) bool {
+	switch s {
+	case OFF_HOOK, DIALING, CONNECTED_DATA, CONNECTED_CMD:
+		return true
+	}
+	return false
+}
+
+func connected(s lineState) bool {
+	return s == CONNECTED_DATA || s == CONNECTED_CMD
+}
+
+func (m *Modem) offHook() bool {
+	return offHook(m.getState())
+}
+
+func (m *Modem) onHook() bool {
+	return !m.offHook()
+}
+
+// Data Carrier Detect: is there a call up?
+func (m *Modem) carrier() bool {
+	return connected(m.getState())
+}
+
+// Is what the DTE sends for the remote, rather than us?
+func (m *Modem) dataMode() bool {
+	return m.getState() == CONNECTED_DATA
+}
+
+func (m *Modem) inState(s lineState) bool {
+	return m.getState() == s
+}
+
+// "Busy" signal: anything but idle.  A ringing line is taken.
+func (m *Modem) checkBusy() bool {
+	return m.getState() != IDLE
+}
+
+// The connection, if there's a call up
+func (m *Modem) activeConn() Connection {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	if !connected(m.state) {
+		return nil
+	}
+	return m.conn
+}
+
+// Ring the line for a call the hunt group's routing to it, if it's idle.
+// Nothing else can take the line until that call's been handed over.
+func (m *Modem) claim(conn Connection) bool {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	if m.state != IDLE {
+		return false
+	}
+	m.changeState(RINGING)
+	m.claimed = conn
+	return true
+}
+
+// The hunt group couldn't hand conn over after all
+func (m *Modem) unclaim(conn Connection) {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	if m.state == RINGING && m.conn == nil && m.claimed == conn {
+		m.changeState(IDLE)
+	}
+}
+
+// Take an inbound call on this line.  It has to be idle, or claimed by
+// the hunt group for this very call (and not turned away since).
+func (m *Modem) takeCall(conn Connection) bool {
+	m.stateLock.Lock()
+	defer m.unlockState()
+	claimed := m.claimed == conn
+	if claimed {
+		m.claimed = nil
+	}
+	switch {
+	case claimed && m.state == RINGING && m.conn == nil:
+	case !claimed && m.state == IDLE:
+		m.changeState(RINGING)
+	default:
+		return false
+	}
+	m.conn = conn
+	return true
+}
+
+// The call on conn is over: forget it, unless another's taken its place
+func (m *Modem) dropCall(conn Connection) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	if m.conn == conn {
+		m.conn = nil
+	}
+}
@@ -0,0 +1,201 @@
+package modem
+
+import (
+	"testing"
+)
+
+func TestStateTransitions(t *testing.T) {
+	tests := []struct {
+		from, to lineState
+		ok       bool
+	}{
+		// Ways into a call
+		{IDLE, DIALING, true},
+		{IDLE, RINGING, true},
+		{IDLE, OFF_HOOK, true},
+		{OFF_HOOK, DIALING, true},
+		{RINGING, CONNECTED_DATA, true},
+		{DIALING, CONNECTED_DATA, true},
+		{DIALING, CONNECTED_CMD, true}, // ATD...;
+		{CONNECTED_DATA, CONNECTED_CMD, true},
+		{CONNECTED_CMD, CONNECTED_DATA, true},
+
+		// And out of one
+		{RINGING, IDLE, true}, // Never answered
+		{RINGING, HANGING_UP, true},
+		{OFF_HOOK, HANGING_UP, true},
+		{DIALING, HANGING_UP, true},
+		{CONNECTED_DATA, HANGING_UP, true},
+		{CONNECTED_CMD, HANGING_UP, true},
+		{HANGING_UP, IDLE, true},
+
+		// Calls don't come up out of nowhere
+		{IDLE, CONNECTED_DATA, false},
+		{IDLE, CONNECTED_CMD, false},
+		{OFF_HOOK, CONNECTED_DATA, false},
+		{OFF_HOOK, RINGING, false},
+		{RINGING, CONNECTED_CMD, false},
+		{RINGING, DIALING, false},
+		{DIALING, RINGING, false},
+		{HANGING_UP, CONNECTED_DATA, false},
+		{HANGING_UP, DIALING, false},
+		{HANGING_UP, RINGING, false},
+
+		// Or go without hanging up
+		{IDLE, HANGING_UP, false},
+		{OFF_HOOK, IDLE, false},
+		{DIALING, IDLE, false},
+		{CONNECTED_DATA, IDLE, false},
+		{CONNECTED_CMD, IDLE, false},
+		{CONNECTED_DATA, DIALING, false},
+		{CONNECTED_CMD, DIALING, false},
+		{CONNECTED_DATA, RINGING, false},
+
+		// Nor stay put
+		{IDLE, IDLE, false},
+		{RINGING, RINGING, false},
+		{CONNECTED_DATA, CONNECTED_DATA, false},
+		{HANGING_UP, HANGING_UP, false},
+	}
+
+	for _, tt := range tests {
+		var m Modem
+		var hooked []stateChange
+		m.onStateChange(func(from, to lineState) {
+			m.getState() // Would deadlock if the state were locked
+			hooked = append(hooked, stateChange{from, to})
+		})
+		m.state = tt.from
+
+		from, ok := m.setState(tt.to)
+		if from != tt.from || ok != tt.ok {
+			t.Errorf("%s -> %s: got %s, %t, want %s, %t", tt.from,
+				tt.to, from, ok, tt.from, tt.ok)
+		}
+
+		want, hooks := tt.from, 0
+		if tt.ok {
+			want, hooks = tt.to, 1
+		}
+		if s := m.getState(); s != want {
+			t.Errorf("%s -> %s: ended up %s", tt.from, tt.to, s)
+		}
+		if len(hooked) != hooks {
+			t.Errorf("%s -> %s: hooks called %d times, want %d",
+				tt.from, tt.to, len(hooked), hooks)
+		}
+	}
+}
+
+// transition() only moves from the state it's told we're in
+func TestStateTransitionFrom(t *testing.T) {
+	tests := []struct {
+		state, from, to lineState
+		ok              bool
+	}{
+		{CONNECTED_DATA, CONNECTED_DATA, CONNECTED_CMD, true},
+		{CONNECTED_CMD, CONNECTED_DATA, CONNECTED_CMD, false},
+		{RINGING, RINGING, IDLE, true},
+		{HANGING_UP, RINGING, IDLE, false}, // Can get to IDLE, but not from here
+		{IDLE, IDLE, CONNECTED_DATA, false},
+	}
+	for _, tt := range tests {
+		var m Modem
+		m.state = tt.state
+		ok := m.transition(tt.from, tt.to)
+		want := tt.state
+		if tt.ok {
+			want = tt.to
+		}
+		if ok != tt.ok || m.getState() != want {
+			t.Errorf("in %s, %s -> %s: got %t and %s, want %t and %s",
+				tt.state, tt.from, tt.to, ok, m.getState(), tt.ok,
+				want)
+		}
+	}
+}
+
+// Wherever the line is, it can get back to idle
+func TestStateNoDeadEnds(t *testing.T) {
+	for s := range stateNames {
+		seen := map[lineState]bool{}
+		next := []lineState{lineState(s)}
+		for len(next) > 0 {
+			n := next[0]
+			next = next[1:]
+			if !seen[n] {
+				seen[n] = true
+				next = append(next, __TRANSITIONS[n]...)
+			}
+		}
+		if lineState(s) != IDLE && !seen[IDLE] {
+			t.Errorf("%s can't get back to %s", lineState(s), IDLE)
+		}
+	}
+}
+
+// Hooks see every change, in order, even ones made by a hook
+func TestStateHookOrder(t *testing.T) {
+	var m Modem
+	var got []stateChange
+	m.onStateChange(func(from, to lineState) {
+		got = append(got, stateChange{from, to})
+		if to == HANGING_UP {
+			m.setState(IDLE)
+		}
+	})
+
+	m.setState(RINGING)
+	m.setState(HANGING_UP)
+
+	want := []stateChange{{IDLE, RINGING}, {RINGING, HANGING_UP},
+		{HANGING_UP, IDLE}}
+	if len(got) != len(want) {
+		t.Fatalf("hooks saw %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d: %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// A call the hunt group's routing to a line can't be beaten to it by
+// another
+func TestStateClaim(t *testing.T) {
+	a, b := &fakeCall{}, &fakeCall{}
+	tests := []struct {
+		name  string
+		setup func(m *Modem)
+		take  Connection
+		ok    bool
+	}{
+		{"direct call", func(m *Modem) {}, a, true},
+		{"claimed for it", func(m *Modem) { m.claim(a) }, a, true},
+		{"claimed for another", func(m *Modem) { m.claim(a) }, b, false},
+		{"turned away", func(m *Modem) {
+			m.claim(a)
+			m.setState(HANGING_UP)
+			m.setState(IDLE)
+		}, a, false},
+		{"unclaimed", func(m *Modem) {
+			m.claim(a)
+			m.unclaim(a)
+		}, b, true},
+		{"unclaimed by another", func(m *Modem) {
+			m.claim(a)
+			m.unclaim(b)
+		}, a, true},
+		{"busy", func(m *Modem) { m.setState(DIALING) }, a, false},
+	}
+	for _, tt := range tests {
+		var m Modem
+		tt.setup(&m)
+		if ok := m.takeCall(tt.take); ok != tt.ok {
+			t.Errorf("%s: took it %t, want %t", tt.name, ok, tt.ok)
+		}
+		if tt.ok && (m.getState() != RINGING || m.conn != tt.take) {
+			t.Errorf("%s: %s with %v", tt.name, m.getState(), m.conn)
+		}
+	}
+}
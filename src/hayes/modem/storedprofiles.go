@@ -1,31 +1,38 @@
-package main
+package modem
 
 import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 )
 
 type configtype struct { // `json:"Config"`
 	Regs map[string]byte `json:"Regs"`
 
 	// Configuration
-	EchoInCmdMode       bool `json:"EchoInCmdMode"`
-	SpeakerMode         int  `json:"SpeakerMode"`
-	SpeakerVolume       int  `json:"SpeakerVolume"`
-	Verbose             bool `json:"Verbose"`
-	Quiet               bool `json:"Quiet"`
-	ConnectMsgSpeed     bool `json:"ConnectMsgSpeed"`
-	BusyDetect          bool `json:"BusyDetect"`
-	ExtendedResultCodes bool `json:"ExtendedResultCodes"`
-	DCDPinned           bool `json:"DCDPinned"`
-	DSRPinned           bool `json:"DSRPinned"`
-	DTR                 int  `json:"DSR"`
+	EchoInCmdMode       bool   `json:"EchoInCmdMode"`
+	SpeakerMode         int    `json:"SpeakerMode"`
+	SpeakerVolume       int    `json:"SpeakerVolume"`
+	Verbose             bool   `json:"Verbose"`
+	Quiet               bool   `json:"Quiet"`
+	ConnectMsgSpeed     bool   `json:"ConnectMsgSpeed"`
+	BusyDetect          bool   `json:"BusyDetect"`
+	BlindDial           bool   `json:"BlindDial,omitempty"`
+	ExtendedResultCodes bool   `json:"ExtendedResultCodes"`
+	DCDPinned           bool   `json:"DCDPinned"`
+	DSRPinned           bool   `json:"DSRPinned"`
+	DTR                 int    `json:"DSR"`
+	FlowControl         int    `json:"FlowControl"`
+	TermType            string `json:"TermType,omitempty"`
+	CallerID            int    `json:"CallerID,omitempty"`
 }
 
 type storedProfiles struct {
 	PowerUpConfig int `json:"PowerUpConfig"`
 	Config        [2]configtype
+	filename      string
+	log           *log.Logger
 }
 
 func (c *configtype) Reset() {
@@ -43,27 +50,32 @@ func (c *configtype) Reset() {
 	c.DCDPinned = false
 	c.DSRPinned = false
 	c.DTR = 0
+	c.FlowControl = FLOW_NONE
+	c.TermType = ""
+	c.CallerID = 0
 }
 
-func newStoredProfiles() (*storedProfiles, error) {
+func newStoredProfiles(filename string, log *log.Logger) (*storedProfiles, error) {
 	var c storedProfiles
 
-	b, err := ioutil.ReadFile("hayes.config.json")
+	c.filename = filename
+	c.log = log
+	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		c.PowerUpConfig = -1
 		c.Config[0].Reset()
 		c.Config[1].Reset()
 		e := fmt.Errorf("Can't read config file: %s", err)
-		logger.Print(e)
+		log.Print(e)
 		return &c, e
 	}
 
 	if err = json.Unmarshal(b, &c); err != nil {
-		logger.Printf("Can't load stored configs: %s", err)
+		log.Printf("Can't load stored configs: %s", err)
 		return &c, err
 	}
 
-	logger.Print("Loaded stored profiles")
+	log.Print("Loaded stored profiles")
 
 	return &c, nil
 }
@@ -71,12 +83,12 @@ func newStoredProfiles() (*storedProfiles, error) {
 func (s *storedProfiles) Write() error {
 	b, err := json.MarshalIndent(s, "", "\t")
 	if err != nil {
-		logger.Print(err)
+		s.log.Print(err)
 		return err
 	}
-	err = ioutil.WriteFile("hayes.config.json", b, 0644)
+	err = ioutil.WriteFile(s.filename, b, 0644)
 	if err != nil {
-		logger.Print(err)
+		s.log.Print(err)
 	}
 	return err
 }
@@ -91,20 +103,11 @@ func (s *storedProfiles) String() string {
 	i := func(p int) string {
 		return fmt.Sprintf("%d ", p)
 	}
-	x := func(r, b bool) string {
-		if r == false && b == false {
-			return "0 "
-		}
-		if r == true && b == false {
-			return "1 "
-		}
-		if r == true && b == true {
-			return "7 "
-		}
-		return "0 "
+	x := func(r, b, blind bool) string {
+		return fmt.Sprintf("%d ", resultLevel(r, b, blind))
 	}
 	r := func(r map[string]byte) string {
-		reg := registersJsonUnmap(r)
+		reg := registersJsonUnmap(r, s.log)
 		return reg.String()
 	}
 
@@ -120,20 +123,25 @@ func (s *storedProfiles) String() string {
 		t += "V" + b(s.Config[p].Verbose)
 		t += "W" + b(s.Config[p].ConnectMsgSpeed)
 		t += "X" +
-			x(s.Config[p].ExtendedResultCodes, s.Config[p].BusyDetect)
+			x(s.Config[p].ExtendedResultCodes, s.Config[p].BusyDetect,
+				s.Config[p].BlindDial)
 		t += "Y0 "
 		t += "&A0 "
 		t += "&C" + b(s.Config[p].DCDPinned)
 		t += "&D" + i(s.Config[p].DTR)
 		t += "&G0 "
 		t += "&J0 "
-		t += "&K0 "
+		t += "&K" + i(s.Config[p].FlowControl)
 		t += "&Q5 "
 		t += "&R0 "
 		t += "&S" + b(s.Config[p].DSRPinned)
 		t += "&T4 "
 		t += "&U0 "
 		t += "&X4 "
+		if s.Config[p].TermType != "" {
+			t += "%T=" + s.Config[p].TermType + " "
+		}
+		t += "+VCID=" + i(s.Config[p].CallerID)
 
 		str += fmt.Sprintf("STORED PROFILE %d:\n", p) + lineWrap(t, 80) +
 			"\n"
@@ -146,12 +154,14 @@ func (s *storedProfiles) String() string {
 	return str
 }
 
-func (s storedProfiles) Switch(i int) error {
+func (s *storedProfiles) Switch(i int, m *Modem) error {
 	if i != 1 && i != 0 {
 		return fmt.Errorf("Invalid stored profile %d", i)
 	}
 
-	logger.Printf("Switching to profile %d", i)
+	s.log.Printf("Switching to profile %d", i)
+	m.serial.setFlowControl(FLOW_NONE) // Let the DTE go first
+	conf := &m.conf
 	conf.Reset()
 	conf.echoInCmdMode = s.Config[i].EchoInCmdMode
 	conf.speakerVolume = s.Config[i].SpeakerVolume
@@ -161,22 +171,26 @@ func (s storedProfiles) Switch(i int) error {
 	conf.connectMsgSpeed = s.Config[i].ConnectMsgSpeed
 	conf.extendedResultCodes = s.Config[i].ExtendedResultCodes
 	conf.busyDetect = s.Config[i].BusyDetect
+	conf.blindDial = s.Config[i].BlindDial
 	conf.dcdPinned = s.Config[i].DCDPinned
 	conf.dsrPinned = s.Config[i].DSRPinned
 	conf.dtr = s.Config[i].DTR
-	registers.Reset()
-	registers = registersJsonUnmap(s.Config[i].Regs)
+	conf.termType = s.Config[i].TermType
+	conf.callerID = s.Config[i].CallerID
+	m.serial.setFlowControl(s.Config[i].FlowControl)
+	m.registers.load(s.Config[i].Regs, s.log)
 
 	return nil
 }
 
 // AT&Wn
-func (s *storedProfiles) writeActive(i int) error {
+func (s *storedProfiles) writeActive(i int, m *Modem) error {
 	if i != 0 && i != 1 {
 		return fmt.Errorf("Invalid config number %d", i)
 	}
 
-	s.Config[i].Regs = registers.JsonMap()
+	conf := &m.conf
+	s.Config[i].Regs = m.registers.JsonMap()
 	s.Config[i].EchoInCmdMode = conf.echoInCmdMode
 	s.Config[i].SpeakerVolume = conf.speakerVolume
 	s.Config[i].SpeakerMode = conf.speakerMode
@@ -185,9 +199,13 @@ func (s *storedProfiles) writeActive(i int) error {
 	s.Config[i].ConnectMsgSpeed = conf.connectMsgSpeed
 	s.Config[i].ExtendedResultCodes = conf.extendedResultCodes
 	s.Config[i].BusyDetect = conf.busyDetect
+	s.Config[i].BlindDial = conf.blindDial
 	s.Config[i].DCDPinned = conf.dcdPinned
 	s.Config[i].DSRPinned = conf.dsrPinned
 	s.Config[i].DTR = conf.dtr
+	s.Config[i].FlowControl = conf.flowControl
+	s.Config[i].TermType = conf.termType
+	s.Config[i].CallerID = conf.callerID
 	
 	return s.Write()
 }
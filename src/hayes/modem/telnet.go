@@ -0,0 +1,485 @@
+package modem
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Telnet negoitation
+const (
+	// COMMANDS
+	IAC  byte = 255
+	DONT byte = 254
+	DO   byte = 253
+	WONT byte = 252
+	WILL byte = 251
+	SB   byte = 250
+	GA   byte = 249
+	EL   byte = 248
+	EC   byte = 247
+	AYT  byte = 246
+	AO   byte = 245
+	IP   byte = 244
+	BRK  byte = 243
+	DM   byte = 242
+	NOP  byte = 241
+	SE   byte = 240
+
+	// SUBNEGOTIATION
+	IS   byte = 0
+	SEND byte = 1
+
+	// OPTIONS
+	BINARY   byte = 0
+	ECHO     byte = 1
+	SGA      byte = 3
+	STATUS   byte = 5
+	TIMINGMK byte = 6
+	TERM     byte = 24
+	WINSIZE  byte = 31
+	TERMSPD  byte = 32
+	REMFLOW  byte = 33
+	LINEMODE byte = 34
+	ENVVAR   byte = 36
+)
+
+var decodeMap map[byte]string = map[byte]string{
+	IAC:      "IAC",
+	DONT:     "DONT",
+	DO:       "DO",
+	WONT:     "WONT",
+	WILL:     "WILL",
+	SB:       "SB",
+	GA:       "GA",
+	EL:       "EL",
+	EC:       "EC",
+	AYT:      "AYT",
+	AO:       "AO",
+	IP:       "IP",
+	BRK:      "BRK",
+	DM:       "DM",
+	NOP:      "NOP",
+	SE:       "SE",
+	BINARY:   "BINARY",
+	ECHO:     "ECHO",
+	SGA:      "SGA",
+	STATUS:   "STATUS",
+	TIMINGMK: "TIMINGMK",
+	TERM:     "TERM",
+	WINSIZE:  "WINSIZE",
+	TERMSPD:  "TERMSPD",
+	REMFLOW:  "REMFLOW",
+	LINEMODE: "LINEMODE",
+	ENVVAR:   "ENVVAR",
+}
+
+func decode(b byte) string {
+	s, ok := decodeMap[b]
+	if !ok {
+		return fmt.Sprintf("!%d ", b)
+	}
+	return s + " "
+}
+
+// Telnet parser states
+const (
+	tsDATA  = iota
+	tsIAC   // Seen IAC
+	tsOPT   // Seen IAC WILL/WONT/DO/DONT
+	tsSBOPT // Seen IAC SB
+	tsSB    // In a subnegotiation
+	tsSBIAC // Seen IAC in a subnegotiation
+)
+
+// What we tell the remote end about ourselves, by default
+const (
+	__TELNET_TERM   = "ANSI"
+	__TELNET_SPEED  = "38400,38400"
+	__TELNET_COLS   = 80
+	__TELNET_ROWS   = 24
+	__TELNET_MAX_SB = 256 // Longest subnegotiation we'll buffer
+)
+
+// How long a caller has to say what terminal it has before the call
+// rings anyway
+const __TELNET_SETTLE = time.Second
+
+// Implements connection for in- and out-bound telnet
+type telnetReadWriteCloser struct {
+	sent, recv uint64 // Atomic, see Connection
+	direction  int
+	c          net.Conn
+	log        *log.Logger
+
+	lock   sync.Mutex // Protects writes and the option state
+	state  int
+	verb   byte
+	sbOpt  byte
+	sbData []byte
+	opts   [256]telnetOption
+
+	binary  byte   // REG_TELNET_BINARY
+	recvCR  bool   // Last data byte received was a CR
+	pending []byte // Data that came in while we waited on options
+
+	// What we tell the remote end
+	localTerm  string
+	localSpeed string
+	localCols  int
+	localRows  int
+
+	// What the remote end told us
+	termType   string
+	cols, rows int
+}
+
+func newTelnet(c net.Conn, direction int, log *log.Logger) *telnetReadWriteCloser {
+	return &telnetReadWriteCloser{direction: direction, c: c, log: log, localTerm: __TELNET_TERM,
+		localSpeed: __TELNET_SPEED, localCols: __TELNET_COLS,
+		localRows: __TELNET_ROWS}
+}
+
+// What the remote told us about its terminal
+func (m *telnetReadWriteCloser) terminal() (string, int, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.termType, m.cols, m.rows
+}
+
+func (m *telnetReadWriteCloser) String() string {
+	var s, p, host string
+	if m.direction == INBOUND {
+		s = "Inbound"
+		p = "from"
+	} else {
+		s = "Outbound"
+		p = "to"
+	}
+	ip, _, err := net.SplitHostPort(m.c.RemoteAddr().String())
+	if err != nil {
+		m.log.Printf("SplitHostPort(): %s", err)
+	}
+	names, err := net.LookupAddr(ip)
+	if err != nil {
+		host = "(nil)"
+		m.log.Printf("LookupAddr(): %s", err)
+	} else {
+		host = names[0]
+	}
+	sent, recv := m.Stats()
+
+	s = fmt.Sprintf("%s %s %s (%s), sent %s, received %s",
+		s, p, host, m.c.RemoteAddr(), 
+		bytefmt.ByteSize(sent), bytefmt.ByteSize(recv))
+
+	m.lock.Lock()
+	s += ", telnet options " + m.optionString()
+	if m.termType != "" {
+		s += fmt.Sprintf(", terminal %s %dx%d", m.termType, m.cols,
+			m.rows)
+	}
+	m.lock.Unlock()
+
+	return s
+}
+
+// The byte following an IAC (other than another IAC).  Must hold
+// m.lock.
+func (m *telnetReadWriteCloser) command(c byte) {
+	m.state = tsDATA
+	switch c {
+	case WILL, WONT, DO, DONT:
+		m.verb = c
+		m.state = tsOPT
+	case SB:
+		m.state = tsSBOPT
+	default: // NOP, GA, AYT, etc.  Nothing to do.
+		m.log.Printf("Received: IAC %s", decode(c))
+	}
+}
+
+// Run received bytes through the telnet parser, compacting the data
+// bytes to the front of p.  Returns the number of data bytes.
+func (m *telnetReadWriteCloser) filter(p []byte) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	n := 0
+	for _, c := range p {
+		switch m.state {
+		case tsDATA:
+			if c == IAC {
+				m.state = tsIAC
+				continue
+			}
+			// Outside of binary mode, CR NUL is a bare CR
+			if c == 0 && m.recvCR && !m.binaryIn() {
+				m.recvCR = false
+				continue
+			}
+			m.recvCR = c == '\r'
+			p[n] = c
+			n++
+
+		case tsIAC:
+			if c == IAC { // Two in a row, it's just ASCII 255
+				m.state = tsDATA
+				m.recvCR = false
+				p[n] = c
+				n++
+			} else {
+				m.command(c)
+			}
+
+		case tsOPT:
+			m.state = tsDATA
+			m.log.Printf("Received: IAC %s%s", decode(m.verb), decode(c))
+			switch m.verb {
+			case WILL:
+				m.receivedEnable(c, true)
+			case WONT:
+				m.receivedDisable(c, true)
+			case DO:
+				m.receivedEnable(c, false)
+			case DONT:
+				m.receivedDisable(c, false)
+			}
+
+		case tsSBOPT:
+			m.sbOpt = c
+			m.sbData = m.sbData[:0]
+			m.state = tsSB
+
+		case tsSB:
+			if c == IAC {
+				m.state = tsSBIAC
+			} else if len(m.sbData) < __TELNET_MAX_SB {
+				m.sbData = append(m.sbData, c)
+			}
+
+		case tsSBIAC:
+			switch c {
+			case IAC: // Escaped 255 within the subnegotiation
+				m.state = tsSB
+				if len(m.sbData) < __TELNET_MAX_SB {
+					m.sbData = append(m.sbData, c)
+				}
+			case SE:
+				m.state = tsDATA
+				m.subnegotiation(m.sbOpt, m.sbData)
+			default:
+				// Missing SE.  Finish the subnegotiation and
+				// treat this as the start of a new command.
+				m.log.Printf("Unterminated SB %s", decode(m.sbOpt))
+				m.subnegotiation(m.sbOpt, m.sbData)
+				m.command(c)
+			}
+		}
+	}
+	return n
+}
+
+func (m *telnetReadWriteCloser) Read(p []byte) (int, error) {
+	m.lock.Lock()
+	if len(m.pending) > 0 {
+		n := copy(p, m.pending)
+		m.pending = m.pending[n:]
+		m.lock.Unlock()
+		return n, nil
+	}
+	m.lock.Unlock()
+
+	for {
+		i, err := m.c.Read(p)
+		i = m.filter(p[:i])
+		atomic.AddUint64(&m.recv, uint64(i))
+
+		// Don't hand back empty reads that were nothing but
+		// telnet commands
+		if i > 0 || err != nil {
+			return i, err
+		}
+	}
+}
+
+// Escape data for the network: IAC is doubled and, outside of binary
+// mode, a CR not followed by LF goes out as CR NUL (RFC 854).  Must
+// hold m.lock.
+func (m *telnetReadWriteCloser) encode(p []byte) []byte {
+	nvt := !m.binaryOut()
+	b := make([]byte, 0, len(p)+1)
+	for i, c := range p {
+		b = append(b, c)
+		switch {
+		case c == IAC:
+			b = append(b, IAC)
+		case c == '\r' && nvt && (i+1 == len(p) || p[i+1] != '\n'):
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// Write raw bytes to the network.  Must hold m.lock.
+func (m *telnetReadWriteCloser) send(p []byte) error {
+	_, err := m.c.Write(p)
+	if err != nil {
+		m.log.Print(err)
+	}
+	return err
+}
+
+func (m *telnetReadWriteCloser) Write(p []byte) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.send(m.encode(p)); err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&m.sent, uint64(len(p)))
+	return len(p), nil
+}
+
+func (m *telnetReadWriteCloser) Close() error {
+	m.log.Printf("Closing telnet connection to %s", m.RemoteAddr())
+	return m.c.Close()
+}
+
+func (m *telnetReadWriteCloser) Direction() int {
+	return m.direction
+}
+
+func (m *telnetReadWriteCloser) RemoteAddr() net.Addr {
+	return m.c.RemoteAddr()
+}
+
+func (m *telnetReadWriteCloser) Stats() (uint64, uint64) {
+	return atomic.LoadUint64(&m.sent), atomic.LoadUint64(&m.recv)
+}
+
+func (m *telnetReadWriteCloser) SetDeadline(t time.Time) error {
+	return m.c.SetDeadline(t)
+}
+
+func acceptTelnet(channel chan Connection, telnetPort uint,
+	screen *callScreen, busy busyFunc, log *log.Logger, ok chan error) {
+
+	port := fmt.Sprintf(":%d", telnetPort)
+	l, err := net.Listen("tcp", port)
+	if err != nil {
+		log.Print("Fatal Error: ", err)
+		ok <- err
+		return
+	}
+	defer l.Close()
+	log.Printf("Listening: telnet tcp/%s", port)
+	ok <- nil
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Printf("l.Accept(): %s\n", err)
+			continue
+		}
+
+		go ringTelnet(conn, channel, screen, busy, log)
+	}
+}
+
+// Screen an inbound telnet call, and ring the modem if it's let in.
+// Must be a goroutine, so one slow caller doesn't hold up the rest.
+func ringTelnet(conn net.Conn, channel chan Connection, screen *callScreen,
+	busy busyFunc, log *log.Logger) {
+
+	if screen.reject(conn.RemoteAddr()) || busy() {
+		conn.Write([]byte("Busy...\n\r"))
+		conn.Close()
+		return
+	}
+
+	// This is a telnet session, negotiate char-at-a-time,
+	// turn off local echo and find out about the terminal
+	t := newTelnet(conn, INBOUND, log)
+	t.lock.Lock()
+	t.request(ECHO, false, true)   // I'll echo to you
+	t.request(SGA, false, true)    // No go-aheads from me
+	t.request(SGA, true, true)     // or from you
+	t.request(TERM, true, true)    // What are you?
+	t.request(WINSIZE, true, true) // How big are you?
+	t.request(TERMSPD, true, true) // How fast are you?
+	t.lock.Unlock()
+	t.settle(__TELNET_SETTLE)
+
+	offerCall(channel, t, busy, log)
+}
+
+// Wait up to d for the caller to answer our questions about its
+// terminal, so it's known before the call rings.  Anything else that
+// arrives is kept for Read.
+func (m *telnetReadWriteCloser) settle(d time.Duration) {
+	m.c.SetReadDeadline(time.Now().Add(d))
+	defer m.c.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 512)
+	for !m.settled() {
+		i, err := m.c.Read(buf)
+		i = m.filter(buf[:i])
+		atomic.AddUint64(&m.recv, uint64(i))
+		m.lock.Lock()
+		m.pending = append(m.pending, buf[:i]...)
+		m.lock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Has the caller answered about its terminal type and size?
+func (m *telnetReadWriteCloser) settled() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	term, size := m.opts[TERM].him, m.opts[WINSIZE].him
+	return term != qWANTYES && size != qWANTYES &&
+		(term != qYES || m.termType != "") &&
+		(size != qYES || m.cols != 0)
+}
+
+func dialTelnet(ctx context.Context, e PhonebookEntry,
+	log *log.Logger) (Connection, error) {
+	remote := e.Host
+
+	if _, _, err := net.SplitHostPort(remote); err != nil {
+		remote += ":23"
+	}
+	log.Printf("Connecting to: %s", remote)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", remote)
+	if err != nil {
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			log.Print("net.Dial: Timed out")
+		} 
+		log.Printf("Error: %s", err)
+		return nil, err
+	}
+
+	log.Printf("Connected to %s", conn.RemoteAddr())
+	t := newTelnet(conn, OUTBOUND, log)
+	if e.TermType != "" {
+		t.localTerm = e.TermType
+	}
+	if e.Speed > 0 {
+		t.localSpeed = fmt.Sprintf("%d,%d", e.Speed, e.Speed)
+	}
+	if e.Cols > 0 {
+		t.localCols = e.Cols
+	}
+	if e.Rows > 0 {
+		t.localRows = e.Rows
+	}
+	return t, nil
+}
@@ -0,0 +1,215 @@
+package modem
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+)
+
+// Stands in for the network under a telnet connection, keeping what's
+// sent.  Anything else it's asked to do panics.
+type fakeConn struct {
+	net.Conn
+	sent bytes.Buffer
+}
+
+func (c *fakeConn) Write(p []byte) (int, error) {
+	return c.sent.Write(p)
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 23}
+}
+
+func testTelnet(direction int) (*telnetReadWriteCloser, *fakeConn) {
+	c := &fakeConn{}
+	return newTelnet(c, direction, log.New(ioutil.Discard, "", 0)), c
+}
+
+// Run b through the receive side, as if it had come off the network
+func (m *telnetReadWriteCloser) receive(b []byte) []byte {
+	p := append([]byte(nil), b...)
+	return p[:m.filter(p)]
+}
+
+func TestTelnetEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		binary bool
+		in     []byte
+		want   []byte
+	}{
+		{"plain", false, []byte("abc"), []byte("abc")},
+		{"IAC doubled", false, []byte{'a', IAC, 'b'},
+			[]byte{'a', IAC, IAC, 'b'}},
+		{"IACs doubled", false, []byte{IAC, IAC}, []byte{IAC, IAC, IAC, IAC}},
+		{"CR LF", false, []byte("a\r\nb"), []byte("a\r\nb")},
+		{"bare CR", false, []byte("a\rb"), []byte("a\r\x00b")},
+		{"CR at the end", false, []byte("a\r"), []byte("a\r\x00")},
+		{"CR CR LF", false, []byte("\r\r\n"), []byte("\r\x00\r\n")},
+		{"CR NUL", false, []byte("\r\x00"), []byte("\r\x00\x00")},
+		{"binary CR", true, []byte("a\rb\r"), []byte("a\rb\r")},
+		{"binary IAC", true, []byte{IAC}, []byte{IAC, IAC}},
+	}
+	for _, tt := range tests {
+		tn, _ := testTelnet(INBOUND)
+		if tt.binary {
+			tn.binary = binaryForce
+		}
+		if got := tn.encode(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: encode(%v) = %v, want %v", tt.name, tt.in,
+				got, tt.want)
+		}
+	}
+}
+
+func TestTelnetFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		binary bool
+		in     []byte
+		want   []byte
+	}{
+		{"plain", false, []byte("abc"), []byte("abc")},
+		{"IAC IAC", false, []byte{'a', IAC, IAC, 'b'},
+			[]byte{'a', IAC, 'b'}},
+		{"IAC IAC IAC IAC", false, []byte{IAC, IAC, IAC, IAC},
+			[]byte{IAC, IAC}},
+		{"CR NUL", false, []byte("a\r\x00b"), []byte("a\rb")},
+		{"CR LF", false, []byte("a\r\nb"), []byte("a\r\nb")},
+		{"NUL alone", false, []byte("a\x00b"), []byte("a\x00b")},
+		{"binary CR NUL", true, []byte("a\r\x00b"), []byte("a\r\x00b")},
+		{"IAC NOP", false, []byte{'a', IAC, NOP, 'b'}, []byte("ab")},
+		{"subnegotiation", false,
+			[]byte{'a', IAC, SB, TERM, IS, 'x', IAC, SE, 'b'},
+			[]byte("ab")},
+		{"IAC in a subnegotiation", false,
+			[]byte{'a', IAC, SB, WINSIZE, 0, IAC, IAC, 0, 24, IAC, SE,
+				'b'},
+			[]byte("ab")},
+		{"nothing but a command", false, []byte{IAC, NOP}, []byte{}},
+	}
+	for _, tt := range tests {
+		tn, _ := testTelnet(INBOUND)
+		if tt.binary {
+			tn.binary = binaryForce
+		}
+		if got := tn.receive(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: filter(%v) = %v, want %v", tt.name, tt.in,
+				got, tt.want)
+		}
+	}
+}
+
+// Whatever one end sends, the other gets, however the network splits
+// it up
+func TestTelnetRoundTrip(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	payloads := []struct {
+		name string
+		p    []byte
+	}{
+		{"0xFF", []byte{0xff}},
+		{"0xFF run", bytes.Repeat([]byte{0xff}, 5)},
+		{"0xFF then CR", []byte{0xff, '\r', 0xff}},
+		{"CR NUL", []byte("\r\x00")},
+		{"CR CR LF", []byte("\r\r\n")},
+		{"every byte", all},
+	}
+	for _, binary := range []bool{false, true} {
+		for _, tt := range payloads {
+			tx, _ := testTelnet(OUTBOUND)
+			if binary {
+				tx.binary = binaryForce
+			}
+			sent := tx.encode(tt.p)
+
+			for split := 0; split <= len(sent); split++ {
+				rx, _ := testTelnet(INBOUND)
+				if binary {
+					rx.binary = binaryForce
+				}
+				got := append(rx.receive(sent[:split]),
+					rx.receive(sent[split:])...)
+				if !bytes.Equal(got, tt.p) {
+					t.Errorf("%s (binary %t), split at %d: got %v, "+
+						"want %v", tt.name, binary, split, got,
+						tt.p)
+				}
+			}
+		}
+	}
+}
+
+// Screen sizes with a 255 in them go out escaped
+func TestTelnetWindowSizeEscaped(t *testing.T) {
+	tn, c := testTelnet(OUTBOUND)
+	tn.opts[WINSIZE].us = qYES
+	tn.resize(255, 511)
+
+	want := []byte{IAC, SB, WINSIZE, 0, IAC, IAC, 1, IAC, IAC, IAC, SE}
+	if got := c.sent.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+
+	rx, _ := testTelnet(INBOUND)
+	rx.opts[WINSIZE].him = qYES
+	rx.receive(c.sent.Bytes())
+	if _, cols, rows := rx.terminal(); cols != 255 || rows != 511 {
+		t.Errorf("received %dx%d, want 255x511", cols, rows)
+	}
+}
+
+// The pumps count bytes as the call's String() and Stats() are read
+func TestTelnetStats(t *testing.T) {
+	local, remote := net.Pipe()
+	m := newTelnet(local, OUTBOUND, log.New(ioutil.Discard, "", 0))
+	defer m.Close()
+	go func() {
+		for i := 0; i < 100; i++ {
+			remote.Write([]byte("abc"))
+		}
+		remote.Close()
+	}()
+	go func() {
+		buf := make([]byte, 10)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for i := 0; i < 100; i++ {
+			m.Write([]byte("xy"))
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		buf := make([]byte, 10)
+		for {
+			if _, err := m.Read(buf); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			m.Stats()
+			_ = m.String()
+		}
+	}
+	if _, recv := m.Stats(); recv != 300 {
+		t.Errorf("received %d, want 300", recv)
+	}
+}
@@ -0,0 +1,292 @@
+package modem
+
+// Telnet option negotiation using the "Q method" from RFC 1143.  Each
+// option has two independent states: whether we (us) and whether the
+// remote end (him) have it enabled.  The WANT states plus a one deep
+// queue are what stop the two ends from looping forever on
+// WILL/DO/WONT/DONT.
+
+import (
+	"fmt"
+)
+
+const (
+	qNO = iota
+	qYES
+	qWANTNO
+	qWANTYES
+)
+
+type telnetOption struct {
+	us, him   int
+	usq, himq bool // Opposite request queued while in a WANT state
+}
+
+// Options we're willing to enable on our side (we send WILL)
+func (t *telnetReadWriteCloser) usOK(opt byte) bool {
+	switch opt {
+	case BINARY, SGA:
+		return true
+	case ECHO:
+		return t.direction == INBOUND // Only a server echoes
+	case TERM, WINSIZE, TERMSPD:
+		return t.direction == OUTBOUND // Only a client reports these
+	}
+	return false
+}
+
+// Options we're willing to let the remote end enable (we send DO)
+func (t *telnetReadWriteCloser) himOK(opt byte) bool {
+	switch opt {
+	case BINARY, SGA:
+		return true
+	case ECHO:
+		return t.direction == OUTBOUND
+	case TERM, WINSIZE, TERMSPD:
+		return t.direction == INBOUND
+	}
+	return false
+}
+
+// 8-bit clean modes, see REG_TELNET_BINARY
+const (
+	binaryOffered = iota // Only if the remote end asks
+	binaryRequest        // Ask for it when the call connects
+	binaryForce          // Always, whatever the remote end says
+)
+
+func (t *telnetReadWriteCloser) setBinary(mode byte) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.binary = mode
+	if mode != binaryOffered {
+		t.log.Print("Requesting telnet binary mode")
+		t.request(BINARY, false, true)
+		t.request(BINARY, true, true)
+	}
+}
+
+// Is the data we receive 8-bit clean?  Must hold t.lock.
+func (t *telnetReadWriteCloser) binaryIn() bool {
+	return t.binary == binaryForce || t.opts[BINARY].him == qYES
+}
+
+// Is the data we send 8-bit clean?  Must hold t.lock.
+func (t *telnetReadWriteCloser) binaryOut() bool {
+	return t.binary == binaryForce || t.opts[BINARY].us == qYES
+}
+
+// Send an option command.  Must hold t.lock.
+func (t *telnetReadWriteCloser) sendOption(cmd, opt byte) {
+	t.log.Printf("Sending: IAC %s%s", decode(cmd), decode(opt))
+	t.send([]byte{IAC, cmd, opt})
+}
+
+// The remote end sent WILL (him == true) or DO (him == false).  Must
+// hold t.lock.
+func (t *telnetReadWriteCloser) receivedEnable(opt byte, him bool) {
+	o := &t.opts[opt]
+	state, queue, ok := &o.us, &o.usq, t.usOK(opt)
+	yes, no := WILL, WONT
+	if him {
+		state, queue, ok = &o.him, &o.himq, t.himOK(opt)
+		yes, no = DO, DONT
+	}
+
+	switch *state {
+	case qNO:
+		if ok {
+			*state = qYES
+			t.sendOption(yes, opt)
+			t.optionEnabled(opt, him)
+		} else {
+			t.sendOption(no, opt)
+		}
+	case qYES:
+		// Already enabled, ignore
+	case qWANTNO:
+		// The remote end is confused, answered our WONT with WILL
+		if *queue {
+			*state = qYES
+			*queue = false
+			t.optionEnabled(opt, him)
+		} else {
+			*state = qNO
+		}
+	case qWANTYES:
+		if *queue {
+			*state = qWANTNO
+			*queue = false
+			t.sendOption(no, opt)
+		} else {
+			*state = qYES
+			t.optionEnabled(opt, him)
+		}
+	}
+}
+
+// The remote end sent WONT (him == true) or DONT (him == false).  Must
+// hold t.lock.
+func (t *telnetReadWriteCloser) receivedDisable(opt byte, him bool) {
+	o := &t.opts[opt]
+	state, queue := &o.us, &o.usq
+	yes, no := WILL, WONT
+	if him {
+		state, queue = &o.him, &o.himq
+		yes, no = DO, DONT
+	}
+
+	switch *state {
+	case qNO:
+		// Already disabled, ignore
+	case qYES:
+		*state = qNO
+		t.sendOption(no, opt)
+	case qWANTNO:
+		if *queue {
+			*state = qWANTYES
+			*queue = false
+			t.sendOption(yes, opt)
+		} else {
+			*state = qNO
+		}
+	case qWANTYES:
+		*state = qNO
+		*queue = false
+	}
+}
+
+// Ask for an option to be turned on or off, on our side (him == false)
+// or the remote's (him == true).  Must hold t.lock.
+func (t *telnetReadWriteCloser) request(opt byte, him bool, enable bool) {
+	o := &t.opts[opt]
+	state, queue := &o.us, &o.usq
+	yes, no := WILL, WONT
+	if him {
+		state, queue = &o.him, &o.himq
+		yes, no = DO, DONT
+	}
+
+	switch *state {
+	case qNO:
+		if enable {
+			*state = qWANTYES
+			t.sendOption(yes, opt)
+		}
+	case qYES:
+		if !enable {
+			*state = qWANTNO
+			t.sendOption(no, opt)
+		}
+	case qWANTNO:
+		*queue = enable
+	case qWANTYES:
+		*queue = !enable
+	}
+}
+
+// An option just became active.  Must hold t.lock.
+func (t *telnetReadWriteCloser) optionEnabled(opt byte, him bool) {
+	switch {
+	case him && (opt == TERM || opt == TERMSPD):
+		// Ask the client what it is
+		t.sendSubneg(opt, []byte{SEND})
+	case !him && opt == WINSIZE:
+		t.sendWindowSize()
+	}
+}
+
+// NAWS: our screen size.  Must hold t.lock.
+func (t *telnetReadWriteCloser) sendWindowSize() {
+	t.sendSubneg(WINSIZE, []byte{byte(t.localCols >> 8),
+		byte(t.localCols), byte(t.localRows >> 8), byte(t.localRows)})
+}
+
+// Our screen changed size, tell the remote if it wants to know
+func (t *telnetReadWriteCloser) resize(cols, rows int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if cols == 0 {
+		cols = __TELNET_COLS
+	}
+	if rows == 0 {
+		rows = __TELNET_ROWS
+	}
+	t.localCols, t.localRows = cols, rows
+	if t.opts[WINSIZE].us == qYES {
+		t.sendWindowSize()
+	}
+}
+
+// Send IAC SB opt <data> IAC SE, escaping any IACs in data.  Must hold
+// t.lock.
+func (t *telnetReadWriteCloser) sendSubneg(opt byte, data []byte) {
+	t.log.Printf("Sending: IAC SB %s%v IAC SE", decode(opt), data)
+	b := []byte{IAC, SB, opt}
+	b = append(b, escapeIAC(data)...)
+	b = append(b, IAC, SE)
+	t.send(b)
+}
+
+// A complete subnegotiation arrived.  Must hold t.lock.
+func (t *telnetReadWriteCloser) subnegotiation(opt byte, data []byte) {
+	t.log.Printf("Received: IAC SB %s%v IAC SE", decode(opt), data)
+
+	switch opt {
+	case TERM:
+		if len(data) == 0 {
+			return
+		}
+		if data[0] == SEND && t.opts[TERM].us == qYES {
+			t.sendSubneg(TERM, append([]byte{IS}, t.localTerm...))
+		} else if data[0] == IS && t.opts[TERM].him == qYES {
+			t.termType = string(data[1:])
+			t.log.Printf("Remote terminal type: %s", t.termType)
+		}
+
+	case TERMSPD:
+		if len(data) == 0 {
+			return
+		}
+		if data[0] == SEND && t.opts[TERMSPD].us == qYES {
+			t.sendSubneg(TERMSPD, append([]byte{IS}, t.localSpeed...))
+		} else if data[0] == IS && t.opts[TERMSPD].him == qYES {
+			t.log.Printf("Remote terminal speed: %s", data[1:])
+		}
+
+	case WINSIZE:
+		if len(data) != 4 || t.opts[WINSIZE].him != qYES {
+			return
+		}
+		t.cols = int(data[0])<<8 | int(data[1])
+		t.rows = int(data[2])<<8 | int(data[3])
+		t.log.Printf("Remote window size: %dx%d", t.cols, t.rows)
+	}
+}
+
+// Describe the options in effect, for debugging
+func (t *telnetReadWriteCloser) optionString() string {
+	var us, him string
+	for i, o := range t.opts {
+		if o.us == qYES {
+			us += decode(byte(i))
+		}
+		if o.him == qYES {
+			him += decode(byte(i))
+		}
+	}
+	return fmt.Sprintf("us: [ %s], him: [ %s]", us, him)
+}
+
+func escapeIAC(p []byte) []byte {
+	var b []byte
+	for _, c := range p {
+		if c == IAC {
+			b = append(b, IAC)
+		}
+		b = append(b, c)
+	}
+	return b
+}
@@ -0,0 +1,235 @@
+package modem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func opt(cmd, opt byte) []byte {
+	return []byte{IAC, cmd, opt}
+}
+
+func cat(b ...[]byte) []byte {
+	return bytes.Join(b, nil)
+}
+
+func (m *telnetReadWriteCloser) ask(opt byte, him, enable bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.request(opt, him, enable)
+}
+
+// The Q method (RFC 1143): whatever the remote end sends, and in
+// whatever order, neither end answers an answer, so there are no loops.
+func TestTelnetNegotiation(t *testing.T) {
+	termSend := []byte{IAC, SB, TERM, SEND, IAC, SE}
+
+	tests := []struct {
+		name      string
+		direction int
+		setup     func(t *telnetReadWriteCloser) // What we did first
+		recv      []byte                         // Then the remote sent
+		want      []byte                         // And we answered
+		opt       byte
+		us, him   int // Where the option ends up
+	}{
+		{
+			name:      "WILL we want",
+			direction: INBOUND,
+			recv:      opt(WILL, TERM),
+			want:      cat(opt(DO, TERM), termSend),
+			opt:       TERM, us: qNO, him: qYES,
+		},
+		{
+			name:      "WILL answering our DO",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(TERM, true, true)
+			},
+			recv: opt(WILL, TERM),
+			want: termSend,
+			opt:  TERM, us: qNO, him: qYES,
+		},
+		{
+			name:      "WILL again",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(TERM, true, true)
+			},
+			recv: cat(opt(WILL, TERM), opt(WILL, TERM), opt(WILL, TERM)),
+			want: termSend,
+			opt:  TERM, us: qNO, him: qYES,
+		},
+		{
+			name:      "WILL we don't want",
+			direction: INBOUND,
+			recv:      opt(WILL, ECHO),
+			want:      opt(DONT, ECHO),
+			opt:       ECHO, us: qNO, him: qNO,
+		},
+		{
+			name:      "WONT answering our DO",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(TERM, true, true)
+			},
+			recv: cat(opt(WONT, TERM), opt(WONT, TERM)),
+			want: nil,
+			opt:  TERM, us: qNO, him: qNO,
+		},
+		{
+			name:      "WONT when it's off",
+			direction: INBOUND,
+			recv:      opt(WONT, TERM),
+			want:      nil,
+			opt:       TERM, us: qNO, him: qNO,
+		},
+		{
+			name:      "DO we will",
+			direction: OUTBOUND,
+			recv:      cat(opt(DO, WINSIZE), opt(DO, WINSIZE)),
+			want: cat(opt(WILL, WINSIZE),
+				[]byte{IAC, SB, WINSIZE, 0, 80, 0, 24, IAC, SE}),
+			opt: WINSIZE, us: qYES, him: qNO,
+		},
+		{
+			name:      "DO we won't",
+			direction: INBOUND,
+			recv:      cat(opt(DO, TERM), opt(DO, TERM)),
+			want:      cat(opt(WONT, TERM), opt(WONT, TERM)),
+			opt:       TERM, us: qNO, him: qNO,
+		},
+		{
+			name:      "DO answering our WILL",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(ECHO, false, true)
+			},
+			recv: opt(DO, ECHO),
+			want: nil,
+			opt:  ECHO, us: qYES, him: qNO,
+		},
+		{
+			name:      "DONT when it's on",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.receive(opt(DO, ECHO))
+			},
+			recv: cat(opt(DONT, ECHO), opt(DONT, ECHO)),
+			want: opt(WONT, ECHO),
+			opt:  ECHO, us: qNO, him: qNO,
+		},
+		{
+			name:      "WILL while asking",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(SGA, true, true)
+				t.ask(SGA, false, true)
+			},
+			recv: cat(opt(WILL, SGA), opt(DO, SGA)),
+			want: nil,
+			opt:  SGA, us: qYES, him: qYES,
+		},
+		{
+			name:      "changed our mind while asking",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(SGA, false, true)
+				t.ask(SGA, false, false) // Queued
+			},
+			recv: cat(opt(DO, SGA), opt(DONT, SGA)),
+			want: opt(WONT, SGA),
+			opt:  SGA, us: qNO, him: qNO,
+		},
+		{
+			name:      "changed our mind back while turning it off",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.receive(opt(DO, SGA))
+				t.ask(SGA, false, false)
+				t.ask(SGA, false, true) // Queued
+			},
+			recv: cat(opt(DONT, SGA), opt(DO, SGA)),
+			want: opt(WILL, SGA),
+			opt:  SGA, us: qYES, him: qNO,
+		},
+		{
+			name:      "refused while asking, then asked",
+			direction: INBOUND,
+			setup: func(t *telnetReadWriteCloser) {
+				t.ask(BINARY, true, true)
+			},
+			recv: cat(opt(WONT, BINARY), opt(WILL, BINARY)),
+			want: opt(DO, BINARY),
+			opt:  BINARY, us: qNO, him: qYES,
+		},
+	}
+
+	for _, tt := range tests {
+		tn, c := testTelnet(tt.direction)
+		if tt.setup != nil {
+			tt.setup(tn)
+		}
+		c.sent.Reset()
+
+		if got := tn.receive(tt.recv); len(got) != 0 {
+			t.Errorf("%s: data %v out of options", tt.name, got)
+		}
+		if got := c.sent.Bytes(); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, got, tt.want)
+		}
+		o := tn.opts[tt.opt]
+		if o.us != tt.us || o.him != tt.him {
+			t.Errorf("%s: us %d him %d, want us %d him %d", tt.name,
+				o.us, o.him, tt.us, tt.him)
+		}
+	}
+}
+
+// Two of us negotiating with each other settle down, and agree
+func TestTelnetNegotiationSettles(t *testing.T) {
+	server, sc := testTelnet(INBOUND)
+	client, cc := testTelnet(OUTBOUND)
+
+	server.ask(ECHO, false, true)
+	server.ask(SGA, false, true)
+	server.ask(SGA, true, true)
+	server.ask(TERM, true, true)
+	server.ask(WINSIZE, true, true)
+	client.ask(SGA, true, true) // Both ask for the same thing
+
+	for i := 0; sc.sent.Len() > 0 || cc.sent.Len() > 0; i++ {
+		if i == 10 {
+			t.Fatalf("still negotiating: server %v, client %v",
+				sc.sent.Bytes(), cc.sent.Bytes())
+		}
+		toClient := append([]byte(nil), sc.sent.Bytes()...)
+		toServer := append([]byte(nil), cc.sent.Bytes()...)
+		sc.sent.Reset()
+		cc.sent.Reset()
+		client.receive(toClient)
+		server.receive(toServer)
+	}
+
+	for _, o := range []struct {
+		opt    byte
+		server int // What the server has on its side
+		client int // And the client
+	}{
+		{ECHO, qYES, qNO},
+		{SGA, qYES, qYES},
+		{TERM, qNO, qYES},
+		{WINSIZE, qNO, qYES},
+	} {
+		s, c := server.opts[o.opt], client.opts[o.opt]
+		if s.us != o.server || c.him != o.server ||
+			s.him != o.client || c.us != o.client {
+			t.Errorf("%s: server %+v, client %+v", decode(o.opt), s, c)
+		}
+	}
+	if term, cols, rows := server.terminal(); term != __TELNET_TERM ||
+		cols != __TELNET_COLS || rows != __TELNET_ROWS {
+		t.Errorf("server thinks the client is %s %dx%d", term, cols,
+			rows)
+	}
+}
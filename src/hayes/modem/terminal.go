@@ -0,0 +1,76 @@
+package modem
+
+import (
+	"strings"
+)
+
+// The screen size per S201/S202, or 0 for the protocol's default
+func (m *Modem) termSize() (int, int) {
+	return int(m.registers.Read(REG_TERM_COLS)),
+		int(m.registers.Read(REG_TERM_ROWS))
+}
+
+// Fill in whatever the entry leaves to the modem's settings
+func (m *Modem) terminalDefaults(e *PhonebookEntry) {
+	if e.TermType == "" {
+		e.TermType = m.conf.termType
+	}
+	cols, rows := m.termSize()
+	if e.Cols == 0 {
+		e.Cols = cols
+	}
+	if e.Rows == 0 {
+		e.Rows = rows
+	}
+	if e.Speed == 0 {
+		e.Speed = m.lineSpeed()
+	}
+}
+
+// S201 or S202 changed: tell the remote, if it cares
+func (m *Modem) resizeTerminal() {
+	conn := m.activeConn()
+	if conn == nil {
+		return
+	}
+	if r, ok := conn.(resizeConn); ok {
+		r.resize(m.termSize())
+	}
+}
+
+// Put the caller's screen size in S203/S204, 0 if conn doesn't know it
+func (m *Modem) remoteTerminal(conn Connection) {
+	var cols, rows int
+	if t, ok := conn.(termConn); ok {
+		_, cols, rows = t.terminal()
+	}
+	if cols > 255 {
+		cols = 255
+	}
+	if rows > 255 {
+		rows = 255
+	}
+	m.registers.Write(REG_REMOTE_COLS, byte(cols))
+	m.registers.Write(REG_REMOTE_ROWS, byte(rows))
+}
+
+// AT%T - show the terminal type, AT%T=type - set it, AT%T=- - back
+// to each protocol's default
+func (m *Modem) terminalType(cmd string) error {
+	if cmd == "%T" || cmd == "%T?" {
+		t := m.conf.termType
+		if t == "" {
+			t = "(default)"
+		}
+		m.serial.Println(t)
+		return OK
+	}
+
+	t := strings.TrimPrefix(cmd, "%T=")
+	if t == "-" {
+		t = ""
+	}
+	m.conf.termType = t
+	m.log.Printf("Terminal type now '%s'", t)
+	return OK
+}
@@ -0,0 +1,22 @@
+package modem
+
+import (
+	"time"
+)
+
+// Timer functions
+func (m *Modem) resetTimer() {
+	m.stopTimer()
+	// REG_ESC_CODE_GUARD_TIME is in 50th's of a second (20ms)
+	gt := m.registers.Read(REG_ESC_CODE_GUARD_TIME)
+	guardTime := time.Duration(float64(gt) * 20) * time.Millisecond
+		
+	m.log.Printf("Setting timer for %v", guardTime)
+	m.timer = time.NewTicker(guardTime)
+}
+
+func (m *Modem) stopTimer() {
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+}
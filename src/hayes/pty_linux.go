@@ -0,0 +1,129 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// A pseudo-terminal acting as the DTE's serial port.  Programs like
+// DOSBox, an emulator or cu(1) open the slave side as if it were a
+// real COM port; we read and write the master side.
+type ptyPort struct {
+	master *os.File
+	slave  *os.File // Held open so the master never sees a hangup
+	name   string   // Path to the slave device (eg, /dev/pts/3)
+	link   string   // Optional symlink to the slave device
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Allocate a new pseudo-terminal, and if link isn't empty, point a
+// symlink at the slave side.
+func openPTY(link string) (*ptyPort, error) {
+	var p ptyPort
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint32
+	if err = ioctl(master.Fd(), syscall.TIOCGPTN,
+		unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("TIOCGPTN: %s", err)
+	}
+
+	var unlock int32
+	if err = ioctl(master.Fd(), syscall.TIOCSPTLCK,
+		unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("TIOCSPTLCK: %s", err)
+	}
+	p.master = master
+	p.name = fmt.Sprintf("/dev/pts/%d", n)
+
+	// Keep our own handle on the slave.  When the last slave
+	// handle closes (eg, cu exits) reads on the master fail with
+	// EIO until somebody else opens it.
+	p.slave, err = os.OpenFile(p.name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	// The line discipline mustn't echo, translate or buffer
+	// anything; we're a serial line, not a terminal.
+	if err = makeRaw(p.slave); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	if link != "" {
+		os.Remove(link) // Clean up after a previous run
+		if err = os.Symlink(p.name, link); err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.link = link
+	}
+
+	return &p, nil
+}
+
+// Equivalent of cfmakeraw(3)
+func makeRaw(f *os.File) error {
+	var t syscall.Termios
+
+	if err := ioctl(f.Fd(), syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return fmt.Errorf("TCGETS: %s", err)
+	}
+
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK |
+		syscall.ISTRIP | syscall.INLCR | syscall.IGNCR |
+		syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON |
+		syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(f.Fd(), syscall.TCSETS, unsafe.Pointer(&t)); err != nil {
+		return fmt.Errorf("TCSETS: %s", err)
+	}
+	return nil
+}
+
+func (p *ptyPort) Name() string {
+	return p.name
+}
+
+func (p *ptyPort) Read(b []byte) (int, error) {
+	return p.master.Read(b)
+}
+
+func (p *ptyPort) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+func (p *ptyPort) Close() error {
+	if p.link != "" {
+		os.Remove(p.link)
+	}
+	if p.slave != nil {
+		p.slave.Close()
+	}
+	return p.master.Close()
+}
@@ -0,0 +1,31 @@
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Pseudo-terminals are only supported on Linux
+type ptyPort struct{}
+
+func openPTY(link string) (*ptyPort, error) {
+	return nil, fmt.Errorf("pty DTE not supported on %s", runtime.GOOS)
+}
+
+func (p *ptyPort) Name() string {
+	return ""
+}
+
+func (p *ptyPort) Read(b []byte) (int, error) {
+	return 0, fmt.Errorf("pty DTE not supported on %s", runtime.GOOS)
+}
+
+func (p *ptyPort) Write(b []byte) (int, error) {
+	return 0, fmt.Errorf("pty DTE not supported on %s", runtime.GOOS)
+}
+
+func (p *ptyPort) Close() error {
+	return nil
+}
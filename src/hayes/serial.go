@@ -3,7 +3,8 @@ package main
 import (
 	"fmt"
 	tarmserial "github.com/tarm/serial"
-	"log"
+	"hayes/modem"
+	"io"
 	"strings"
 )
 
@@ -30,136 +31,86 @@ char getch(){
 */
 import "C"
 
-type serialPort struct {
-	console bool
-	port    *tarmserial.Port
-	log     *log.Logger
-	channel chan byte
+// stdin/stdout as the DTE.  Some static key mapping is needed, which
+// depends on the modem's registers.
+type consolePort struct {
+	m *modem.Modem
 }
 
-func setupSerialPort(port string, speed int) *serialPort {
-	var s serialPort
+// Open the DTE side of the modem
+func setupSerialPort(port string, speed int, ptyLink string) (io.ReadWriteCloser, *consolePort) {
 
-	s.console = port == ""
-	s.channel = make(chan byte)
-
-	if s.console {
+	switch {
+	case port == "":
 		logger.Print("Using stdin/stdout as DTE")
-	} else {
+		c := &consolePort{}
+		return c, c
 
-		logger.Printf("Using serial port %s at %d bps", port, speed)
-		c := &tarmserial.Config{Name: port, Baud: speed}
-		p, err := tarmserial.OpenPort(c)
+	case port == "pty":
+		p, err := openPTY(ptyLink)
 		if err != nil {
 			logger.Fatal(err)
 		}
-		s.port = p
-	}
-
-	go s.getChars()
-	return &s
-}
-
-func (s *serialPort) Flush() error {
-	if s.console || s.port == nil {
-		return nil
-	}
-
-	logger.Print("flushing serial port")
-	return s.port.Flush()
-}
-
-func (s *serialPort) Read(p []byte) (int, error) {
-	if s.console {
-		p[0] = byte(C.getch())
-		// mappings
-		switch p[0] {
-		case 127:
-			p[0] = registers.Read(REG_BS_CH)
-		case '\n':
-			p[0] = registers.Read(REG_CR_CH)
+		logger.Printf("Using pseudo-terminal %s as DTE", p.Name())
+		if ptyLink != "" {
+			logger.Printf("Linked %s -> %s", ptyLink, p.Name())
 		}
-		return 1, nil
-	}
-
-	return s.port.Read(p)
-}
+		return p, nil
 
-func (s *serialPort) getChars() {
-
-	in := make([]byte, 1)
-	for {
-		if _, err := s.Read(in); err != nil {
-			logger.Print("Read(): ", err)
+	case strings.HasPrefix(port, "tcp:"):
+		p, err := openTCPPort(strings.TrimPrefix(port, "tcp:"))
+		if err != nil {
+			logger.Fatal(err)
 		}
-
-		s.channel <- in[0]
+		logger.Printf("Waiting for DTE on tcp/%s", p.Addr())
+		return p, nil
 	}
-}
-
-func (s *serialPort) Write(p []byte) (int, error) {
-	if s.console {
-		// If we're writing to stdout, some static key mapping
-		// is needed
-
-		// Ignore anything above ASCII 127 or the ASCII escape
-		if p[0] > 127 || p[0] == 27 {
-			return 0, nil
-		}
-		// ASCII DEL -> ASCII BS
-		if p[0] == 127 {
-			p[0] = registers.Read(REG_BS_CH)
-		}
-		// end of key mappings
-
-		// Handle BS
-		str := string(p)
-		if p[0] == registers.Read(REG_BS_CH) {
-			str = fmt.Sprintf("%c %c", registers.Read(REG_BS_CH),
-				registers.Read(REG_BS_CH))
-		}
 
-		// This should be the only fmt.Print* in the codebase
-		return fmt.Printf("%s", str)
+	logger.Printf("Using serial port %s at %d bps", port, speed)
+	c := &tarmserial.Config{Name: port, Baud: speed}
+	p, err := tarmserial.OpenPort(c)
+	if err != nil {
+		logger.Fatal(err)
 	}
-
-	return s.port.Write(p)
+	return p, nil
 }
 
-func (s *serialPort) WriteByte(p byte) (int, error) {
-	var out []byte
-
-	// map '\n' to '\n\r'
-	switch p {
-	case registers.Read(REG_CR_CH):
-		out = make([]byte, 2)
-		out[0] = p
-		out[1] = registers.Read(REG_LF_CH)
-	default:
-		out = make([]byte, 1)
-		out[0] = p
+func (s *consolePort) Read(p []byte) (int, error) {
+	p[0] = byte(C.getch())
+	// mappings
+	switch p[0] {
+	case 127:
+		p[0] = s.m.Register(modem.REG_BS_CH)
+	case '\n':
+		p[0] = s.m.Register(modem.REG_CR_CH)
 	}
-
-	return s.Write(out)
+	return 1, nil
 }
 
-func (s *serialPort) Printf(format string, a ...interface{}) error {
-	out := fmt.Sprintf(format, a...)
-	out = strings.Replace(out, "\n", "\n\r", -1)
-	_, err := s.Write([]byte(out))
-	return err
-}
+func (s *consolePort) Write(p []byte) (int, error) {
+	// If we're writing to stdout, some static key mapping
+	// is needed, byte by byte
+	bs := s.m.Register(modem.REG_BS_CH)
+
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		switch {
+		case c > 127 || c == 27: // Ignore anything above ASCII 127 or the ASCII escape
+		case c == 127 || c == bs: // ASCII DEL -> ASCII BS, and rub out
+			out = append(out, bs, ' ', bs)
+		default:
+			out = append(out, c)
+		}
+	}
+	// end of key mappings
 
-func (s *serialPort) Print(a ...interface{}) error {
-	if a == nil {
-		return nil
+	// This should be the only fmt.Print* in the codebase
+	if _, err := fmt.Printf("%s", out); err != nil {
+		return 0, err
 	}
-	return s.Printf("%s", a...)
+	return len(p), nil
 }
 
-func (s *serialPort) Println(a ...interface{}) error {
-	if a == nil {
-		return s.Printf("\n")
-	}
-	return s.Printf("%s\n", a...)
+func (s *consolePort) Close() error {
+	return nil
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net"
+	"sync"
+)
+
+// A TCP socket acting as the DTE's serial port.  Emulators (VICE,
+// DOSBox-X, 86Box, MAME, ...) that expose their COM port as a TCP
+// client connect here and become the RS-232 line.  Only one
+// connection is serviced at a time; the next one is accepted once
+// the current DTE goes away.
+type tcpPort struct {
+	l      net.Listener
+	conn   net.Conn
+	closed bool
+	lock   sync.Mutex
+	cond   *sync.Cond
+}
+
+func openTCPPort(address string) (*tcpPort, error) {
+	var p tcpPort
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	p.l = l
+	p.cond = sync.NewCond(&p.lock)
+
+	go p.accept()
+	return &p, nil
+}
+
+func (p *tcpPort) Addr() net.Addr {
+	return p.l.Addr()
+}
+
+func (p *tcpPort) accept() {
+	for {
+		c, err := p.l.Accept()
+		if err != nil {
+			p.lock.Lock()
+			closed := p.closed
+			p.lock.Unlock()
+			if closed {
+				return
+			}
+			logger.Printf("DTE l.Accept(): %s", err)
+			continue
+		}
+
+		p.lock.Lock()
+		if p.conn != nil {
+			p.lock.Unlock()
+			logger.Printf("DTE already connected, rejecting %s",
+				c.RemoteAddr())
+			c.Close()
+			continue
+		}
+		logger.Printf("DTE connected from %s", c.RemoteAddr())
+		p.conn = c
+		p.cond.Broadcast()
+		p.lock.Unlock()
+	}
+}
+
+// The DTE went away, wait for the next one.
+func (p *tcpPort) drop(c net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.conn == c {
+		p.conn = nil
+	}
+	c.Close()
+}
+
+// Blocks until a DTE is connected
+func (p *tcpPort) Read(b []byte) (int, error) {
+	for {
+		p.lock.Lock()
+		for p.conn == nil && !p.closed {
+			p.cond.Wait()
+		}
+		c := p.conn
+		closed := p.closed
+		p.lock.Unlock()
+		if closed {
+			return 0, net.ErrClosed
+		}
+
+		i, err := c.Read(b)
+		if err == nil {
+			return i, nil
+		}
+		logger.Printf("DTE %s disconnected: %s", c.RemoteAddr(), err)
+		p.drop(c)
+		if i > 0 {
+			return i, nil
+		}
+	}
+}
+
+// With nobody connected, output goes nowhere; just like a serial port
+// with nothing plugged into it.
+func (p *tcpPort) Write(b []byte) (int, error) {
+	p.lock.Lock()
+	c := p.conn
+	p.lock.Unlock()
+	if c == nil {
+		return len(b), nil
+	}
+
+	i, err := c.Write(b)
+	if err != nil {
+		logger.Printf("DTE %s disconnected: %s", c.RemoteAddr(), err)
+		p.drop(c)
+		return len(b), nil
+	}
+	return i, nil
+}
+
+func (p *tcpPort) Close() error {
+	p.lock.Lock()
+	p.closed = true
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.cond.Broadcast()
+	p.lock.Unlock()
+	return p.l.Close()
+}